@@ -5,10 +5,14 @@ go 1.23.0
 toolchain go1.24.1
 
 require (
+	github.com/BurntSushi/toml v1.5.0 // app dep
 	github.com/golangci/golangci-lint v1.64.8
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1 // test dep
 	github.com/stretchr/testify v1.11.1
 	github.com/urfave/cli/v2 v2.27.7 // app dep
 	github.com/yuin/goldmark v1.8.2 // app dep
+	golang.org/x/net v0.43.0 // app dep
+	gopkg.in/yaml.v3 v3.0.1 // app dep
 	honnef.co/go/tools v0.6.1 // indirect
 	mvdan.cc/gofumpt v0.7.0 // indirect
 )
@@ -25,7 +29,6 @@ require (
 	github.com/Antonboom/errname v1.0.0 // indirect
 	github.com/Antonboom/nilnil v1.0.1 // indirect
 	github.com/Antonboom/testifylint v1.5.2 // indirect
-	github.com/BurntSushi/toml v1.5.0 // indirect
 	github.com/Crocmagnon/fatcontext v0.7.1 // indirect
 	github.com/Djarvur/go-err113 v0.0.0-20210108212216-aea10b59be24 // indirect
 	github.com/GaijinEntertainment/go-exhaustruct/v3 v3.3.1 // indirect
@@ -210,6 +213,5 @@ require (
 	google.golang.org/protobuf v1.36.5 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 	mvdan.cc/unparam v0.0.0-20240528143540-8a5130ca722f // indirect
 )
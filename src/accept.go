@@ -0,0 +1,88 @@
+package src
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// acceptRule treats the status codes of a range as a success of the given
+// severity.
+type acceptRule struct {
+	Status   statusRange
+	Severity severity
+}
+
+// acceptPolicy decides which status codes mark a link as alive. Codes below
+// 400 always do, the rules add to them.
+type acceptPolicy struct {
+	rules []acceptRule
+}
+
+// newAcceptPolicy parses the --accept values.
+func newAcceptPolicy(values []string) (*acceptPolicy, error) {
+	p := &acceptPolicy{}
+	for _, v := range values {
+		rule, err := parseAcceptRule(v)
+		if err != nil {
+			return nil, fmt.Errorf("accept: %w", err)
+		}
+		p.rules = append(p.rules, rule)
+	}
+	return p, nil
+}
+
+// parseAcceptRule parses a status code, range or class like 403, 401-403 or
+// 4xx, optionally followed by :ok or :warning. Codes up to 999 are allowed
+// since some hosts answer bots with made up ones.
+func parseAcceptRule(s string) (acceptRule, error) {
+	status, level, hasLevel := strings.Cut(strings.TrimSpace(strings.ToLower(s)), ":")
+	rule := acceptRule{Severity: severityOK}
+	if hasLevel {
+		switch sev := severity(strings.TrimSpace(level)); sev {
+		case severityOK, severityWarning:
+			rule.Severity = sev
+		default:
+			return acceptRule{}, fmt.Errorf(
+				"invalid level %q in %q, expected ok or warning", level, s,
+			)
+		}
+	}
+
+	r, err := parseStatusRange(status)
+	if err != nil {
+		return acceptRule{}, err
+	}
+	rule.Status = r
+	return rule, nil
+}
+
+// accepts reports whether a response with the status code is a live link
+// and with which severity. The first matching rule wins. A nil policy only
+// accepts codes below 400.
+func (p *acceptPolicy) accepts(code int) (severity, bool) {
+	if p != nil {
+		for _, r := range p.rules {
+			if r.Status.contains(code) {
+				return r.Severity, true
+			}
+		}
+	}
+	if code < 400 {
+		return severityOK, true
+	}
+	return "", false
+}
+
+// acceptedMessage describes an accepted status code. Codes that are errors
+// on their own say that they were accepted.
+func acceptedMessage(code int) string {
+	message := http.StatusText(code)
+	if message == "" {
+		message = fmt.Sprintf("Status %d", code)
+	}
+	if code >= 400 {
+		message += ", accepted"
+	}
+	return message
+}
@@ -0,0 +1,171 @@
+package src
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statusServer answers every request with the status code in its path,
+// like /403.
+func statusServer() *httptest.Server {
+	return httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var code int
+			_, _ = fmt.Sscanf(r.URL.Path, "/%d", &code)
+			w.WriteHeader(code)
+		}),
+	)
+}
+
+func TestParseAcceptRule(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		value string
+		want  acceptRule
+		err   string
+	}{
+		{"403", acceptRule{statusRange{403, 403}, severityOK}, ""},
+		{"999", acceptRule{statusRange{999, 999}, severityOK}, ""},
+		{"401-403:warning", acceptRule{statusRange{401, 403}, severityWarning}, ""},
+		{" 4XX : OK ", acceptRule{statusRange{400, 499}, severityOK}, ""},
+		{"403:error", acceptRule{}, `invalid level "error" in "403:error"`},
+		{"1000", acceptRule{}, `invalid status "1000"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseAcceptRule(tt.value)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAcceptPolicy_Accepts(t *testing.T) {
+	t.Parallel()
+	p, err := newAcceptPolicy([]string{"403:warning", "4xx", "200:warning"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		code int
+		want severity
+		ok   bool
+	}{
+		{200, severityWarning, true},
+		{301, severityOK, true},
+		{403, severityWarning, true},
+		{404, severityOK, true},
+		{500, "", false},
+	}
+	for _, tt := range tests {
+		sev, ok := p.accepts(tt.code)
+		assert.Equal(t, tt.want, sev, tt.code)
+		assert.Equal(t, tt.ok, ok, tt.code)
+	}
+
+	// Without rules, only codes below 400 are alive
+	var none *acceptPolicy
+	_, ok := none.accepts(403)
+	assert.False(t, ok)
+	sev, ok := none.accepts(204)
+	assert.Equal(t, severityOK, sev)
+	assert.True(t, ok)
+
+	_, err = newAcceptPolicy([]string{"forbidden"})
+	assert.ErrorContains(t, err, `accept: invalid status "forbidden"`)
+}
+
+func TestCheckLinkWith_Accept(t *testing.T) {
+	t.Parallel()
+	ts := statusServer()
+	defer ts.Close()
+
+	accept, err := newAcceptPolicy([]string{"999", "401:warning"})
+	require.NoError(t, err)
+	cfg := requestConfig{Timeout: time.Second, MaxRetries: 3, Accept: accept}
+
+	lr := checkLinkWith(ts.URL+"/999", cfg)
+	assert.True(t, lr.OK)
+	assert.Equal(t, "Status 999, accepted", lr.Message)
+	assert.Empty(t, lr.Warning)
+	assert.Equal(t, 1, lr.Attempt)
+
+	lr = checkLinkWith(ts.URL+"/401", cfg)
+	assert.True(t, lr.OK)
+	assert.Equal(t, "Unauthorized, accepted", lr.Message)
+	assert.Equal(t, "Status 401 is accepted as a warning", lr.Warning)
+	assert.Equal(t, severityWarning, classify(lr))
+
+	lr = checkLinkWith(ts.URL+"/403", cfg)
+	assert.False(t, lr.OK)
+}
+
+func TestChecker_AcceptHost(t *testing.T) {
+	t.Parallel()
+	ts := statusServer()
+	defer ts.Close()
+
+	accept, err := newAcceptPolicy([]string{"403:warning"})
+	require.NoError(t, err)
+	c := newChecker(options{
+		Timeout:    time.Second,
+		MaxRetries: 1,
+		Accept:     accept,
+		Hosts: map[string]hostConfig{
+			"127.0.0.1": {Accept: &acceptPolicy{rules: []acceptRule{
+				{statusRange{403, 403}, severityOK},
+			}}},
+		},
+	})
+	defer c.close()
+
+	// The host's rules replace the global ones
+	lr := c.check(ts.URL + "/403")
+	assert.True(t, lr.OK)
+	assert.Equal(t, severityOK, classify(lr))
+
+	lr = c.check(strings.Replace(ts.URL, "127.0.0.1", "localhost", 1) + "/403")
+	assert.True(t, lr.OK)
+	assert.Equal(t, severityWarning, classify(lr))
+}
+
+func TestCLI_Accept(t *testing.T) {
+	ts := statusServer()
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "links.md")
+	require.NoError(t, os.WriteFile(path, []byte("[bot]("+ts.URL+"/999)"), 0o600))
+
+	run := func(args ...string) (string, int) {
+		code := 0
+		var out bytes.Buffer
+		os.Args = append([]string{os.Args[0], "-f", path}, args...)
+		CLI(&out, "0.1.0-test", func(c int) { code = c })
+		return out.String(), code
+	}
+
+	out, code := run()
+	assert.Contains(t, out, "Severity   : error")
+	assert.Equal(t, 1, code)
+
+	out, code = run("--accept", "999")
+	assert.Contains(t, out, "Message    : Status 999, accepted")
+	assert.Equal(t, 0, code)
+
+	_, code = run("--accept", "9xx")
+	assert.Equal(t, 2, code)
+}
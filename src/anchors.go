@@ -0,0 +1,189 @@
+package src
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	"golang.org/x/net/html"
+)
+
+// slugFunc turns the text of a heading into its anchor.
+type slugFunc func(heading string) string
+
+// slugAlgorithms maps the names accepted by --slug to their slug functions.
+var slugAlgorithms = map[string]slugFunc{
+	"github":    githubSlug,
+	"gitlab":    gitlabSlug,
+	"bitbucket": bitbucketSlug,
+}
+
+// githubSlug lowercases the heading, drops everything except letters,
+// numbers, underscores, hyphens and spaces, and turns spaces into hyphens.
+func githubSlug(heading string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(heading) {
+		switch {
+		case r == ' ':
+			sb.WriteRune('-')
+		case r == '-' || r == '_',
+			unicode.IsLetter(r), unicode.IsNumber(r), unicode.IsMark(r):
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// gitlabSlug works like githubSlug but also collapses runs of hyphens and
+// trims the surrounding whitespace first.
+func gitlabSlug(heading string) string {
+	slug := githubSlug(strings.TrimSpace(heading))
+	for strings.Contains(slug, "--") {
+		slug = strings.ReplaceAll(slug, "--", "-")
+	}
+	return slug
+}
+
+// bitbucketSlug prefixes the gitlab style slug like Bitbucket does.
+func bitbucketSlug(heading string) string {
+	return "markdown-header-" + gitlabSlug(heading)
+}
+
+// htmlAnchors adds the value of every id and name attribute in the HTML
+// read from r to anchors.
+func htmlAnchors(r io.Reader, anchors map[string]bool) {
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return
+		case html.StartTagToken, html.SelfClosingTagToken:
+			_, hasAttr := z.TagName()
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				if (string(key) == "id" || string(key) == "name") && len(val) > 0 {
+					anchors[string(val)] = true
+				}
+			}
+		}
+	}
+}
+
+// findAnchors returns every anchor a markdown document defines: the slugs
+// of its headings and the id and name attributes of its inline HTML.
+// Duplicate heading slugs get a numeric suffix like on GitHub.
+func findAnchors(markdown []byte, slug slugFunc) (map[string]bool, error) {
+	anchors := make(map[string]bool)
+	document := goldmark.DefaultParser().Parse(text.NewReader(markdown))
+
+	err := ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch n := node.(type) {
+		case *ast.Heading:
+			base := slug(nodeText(n, markdown))
+			if base == "" {
+				break
+			}
+			anchor := base
+			for i := 1; anchors[anchor]; i++ {
+				anchor = fmt.Sprintf("%s-%d", base, i)
+			}
+			anchors[anchor] = true
+		case *ast.RawHTML:
+			var buf bytes.Buffer
+			for i := 0; i < n.Segments.Len(); i++ {
+				segment := n.Segments.At(i)
+				buf.Write(segment.Value(markdown))
+			}
+			htmlAnchors(&buf, anchors)
+		case *ast.HTMLBlock:
+			var buf bytes.Buffer
+			for i := 0; i < n.Lines().Len(); i++ {
+				line := n.Lines().At(i)
+				buf.Write(line.Value(markdown))
+			}
+			htmlAnchors(&buf, anchors)
+		}
+		return ast.WalkContinue, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to traverse markdown AST: %w", err)
+	}
+	return anchors, nil
+}
+
+// anchorSet holds the anchors of a single file once they're computed.
+type anchorSet struct {
+	once    sync.Once
+	anchors map[string]bool
+	err     error
+}
+
+// anchorIndex computes the anchors of local files on demand and keeps them
+// for the rest of the run. It's safe for concurrent use.
+type anchorIndex struct {
+	slug  slugFunc
+	mu    sync.Mutex
+	files map[string]*anchorSet
+}
+
+func newAnchorIndex(slug slugFunc) *anchorIndex {
+	if slug == nil {
+		slug = githubSlug
+	}
+	return &anchorIndex{slug: slug, files: make(map[string]*anchorSet)}
+}
+
+// lookup returns the anchors defined in the file at path. Only markdown and
+// HTML files have anchors, for anything else lookup returns nil.
+func (a *anchorIndex) lookup(path string) (map[string]bool, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if !isMarkdown(path) && ext != ".html" && ext != ".htm" {
+		return nil, nil
+	}
+
+	a.mu.Lock()
+	set, ok := a.files[path]
+	if !ok {
+		set = &anchorSet{}
+		a.files[path] = set
+	}
+	a.mu.Unlock()
+
+	set.once.Do(func() {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			set.err = fmt.Errorf("failed to read file: %w", err)
+			return
+		}
+		if isMarkdown(path) {
+			set.anchors, set.err = findAnchors(content, a.slug)
+			return
+		}
+		set.anchors = make(map[string]bool)
+		htmlAnchors(bytes.NewReader(content), set.anchors)
+	})
+	return set.anchors, set.err
+}
+
+// hasAnchor reports whether the fragment points to one of the anchors.
+// The "top" fragment always resolves, just like in browsers.
+func hasAnchor(anchors map[string]bool, fragment string) bool {
+	if unescaped, err := url.PathUnescape(fragment); err == nil {
+		fragment = unescaped
+	}
+	return anchors[fragment] || strings.EqualFold(fragment, "top")
+}
@@ -0,0 +1,155 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlugAlgorithms(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		heading   string
+		github    string
+		gitlab    string
+		bitbucket string
+	}{
+		{
+			"Installation",
+			"installation",
+			"installation",
+			"markdown-header-installation",
+		},
+		{
+			"Getting Started!",
+			"getting-started",
+			"getting-started",
+			"markdown-header-getting-started",
+		},
+		{
+			"A -- B",
+			"a----b",
+			"a-b",
+			"markdown-header-a-b",
+		},
+		{
+			"snake_case & `code`",
+			"snake_case--code",
+			"snake_case-code",
+			"markdown-header-snake_case-code",
+		},
+		{
+			"Café Ünïcödé",
+			"café-ünïcödé",
+			"café-ünïcödé",
+			"markdown-header-café-ünïcödé",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.heading, func(t *testing.T) {
+			assert.Equal(t, tt.github, githubSlug(tt.heading))
+			assert.Equal(t, tt.gitlab, gitlabSlug(tt.heading))
+			assert.Equal(t, tt.bitbucket, bitbucketSlug(tt.heading))
+		})
+	}
+}
+
+func TestFindAnchors(t *testing.T) {
+	t.Parallel()
+	markdown := []byte(`# Setup
+
+## Prerequisites
+
+## Prerequisites
+
+Some <a name="inline-anchor"></a> text.
+
+<div id='block-anchor'>
+  <span id=bare>x</span>
+</div>
+`)
+
+	anchors, err := findAnchors(markdown, githubSlug)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]bool{
+		"setup":           true,
+		"prerequisites":   true,
+		"prerequisites-1": true,
+		"inline-anchor":   true,
+		"block-anchor":    true,
+		"bare":            true,
+	}, anchors)
+}
+
+func TestHasAnchor(t *testing.T) {
+	t.Parallel()
+	anchors := map[string]bool{"café": true, "setup": true}
+
+	assert.True(t, hasAnchor(anchors, "setup"))
+	assert.True(t, hasAnchor(anchors, "caf%C3%A9"))
+	assert.True(t, hasAnchor(anchors, "top"))
+	assert.False(t, hasAnchor(anchors, "Setup"))
+	assert.False(t, hasAnchor(anchors, "missing"))
+}
+
+func TestAnchorIndex_Lookup(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	md := filepath.Join(root, "a.md")
+	html := filepath.Join(root, "b.html")
+	png := filepath.Join(root, "c.png")
+	require.NoError(t, os.WriteFile(md, []byte("# Hello World"), 0o600))
+	require.NoError(t, os.WriteFile(html, []byte(`<h1 id="title">x</h1>`), 0o600))
+	require.NoError(t, os.WriteFile(png, []byte("png"), 0o600))
+
+	index := newAnchorIndex(slugAlgorithms["gitlab"])
+
+	anchors, err := index.lookup(md)
+	require.NoError(t, err)
+	assert.True(t, anchors["hello-world"])
+
+	anchors, err = index.lookup(html)
+	require.NoError(t, err)
+	assert.True(t, anchors["title"])
+
+	anchors, err = index.lookup(png)
+	require.NoError(t, err)
+	assert.Nil(t, anchors, "only markdown and HTML files have anchors")
+}
+
+func TestCheckLocalLink_Anchors(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	index := filepath.Join(root, "index.md")
+	setup := filepath.Join(root, "setup.md")
+	require.NoError(t, os.WriteFile(
+		index, []byte("# Installation\n\n[a](#installation) [b](#gone)"), 0o600,
+	))
+	require.NoError(t, os.WriteFile(setup, []byte("## Prerequisites"), 0o600))
+
+	anchors := newAnchorIndex(githubSlug)
+	tests := []struct {
+		link    string
+		ok      bool
+		message string
+	}{
+		{"#installation", true, "OK"},
+		{"#gone", false, "Anchor #gone not found in " + index},
+		{"setup.md#prerequisites", true, "OK"},
+		{"setup.md#requirements", false, "Anchor #requirements not found in " + setup},
+		{"./#anything", true, "OK"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			lr := checkLocalLink(tt.link, index, root, anchors)
+			assert.Equal(t, tt.ok, lr.OK)
+			assert.Equal(t, tt.message, lr.Message)
+		})
+	}
+}
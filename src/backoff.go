@@ -0,0 +1,88 @@
+package src
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"time"
+)
+
+// backoffState is what backoff strategies compute the next delay from. Prev
+// is the previous delay, 0 before the first retry.
+type backoffState struct {
+	Start time.Duration
+	Max   time.Duration
+	Prev  time.Duration
+	Rand  *rand.Rand
+}
+
+// backoffFunc returns how long to wait after the failed attempt, counting
+// from 1.
+type backoffFunc func(s *backoffState, attempt int) time.Duration
+
+// backoffStrategies maps the names accepted by --backoff to their functions.
+var backoffStrategies = map[string]backoffFunc{
+	"exponential":         exponentialBackoff,
+	"full-jitter":         fullJitterBackoff,
+	"decorrelated-jitter": decorrelatedJitterBackoff,
+	"constant":            constantBackoff,
+}
+
+// next returns the delay after the failed attempt, capped at Max.
+func (s *backoffState) next(fn backoffFunc, attempt int) time.Duration {
+	d := min(fn(s, attempt), s.Max)
+	s.Prev = d
+	return d
+}
+
+// jitter returns a random duration in [0, d).
+func (s *backoffState) jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(s.Rand.Int63n(int64(d)))
+}
+
+// growth returns Start doubled once per previous attempt, without going
+// past Max.
+func (s *backoffState) growth(attempt int) time.Duration {
+	d := s.Start
+	for i := 1; i < attempt && d < s.Max; i++ {
+		d *= 2
+	}
+	return min(d, s.Max)
+}
+
+// exponentialBackoff doubles the delay after every attempt and adds up to
+// 100ms of jitter.
+func exponentialBackoff(s *backoffState, attempt int) time.Duration {
+	return s.growth(attempt) + s.jitter(100*time.Millisecond)
+}
+
+// fullJitterBackoff picks a random delay between 0 and the exponential one.
+func fullJitterBackoff(s *backoffState, attempt int) time.Duration {
+	return s.jitter(s.growth(attempt) + 1)
+}
+
+// decorrelatedJitterBackoff picks a random delay between Start and three
+// times the previous one.
+func decorrelatedJitterBackoff(s *backoffState, _ int) time.Duration {
+	prev := max(s.Prev, s.Start)
+	return s.Start + s.jitter(3*prev-s.Start+1)
+}
+
+// constantBackoff always waits Start.
+func constantBackoff(s *backoffState, _ int) time.Duration {
+	return s.Start
+}
+
+// backoffRand returns the random source for the retries of url. A non zero
+// seed makes the delays reproducible. Each URL gets its own source so that
+// the order in which concurrent checks run doesn't matter.
+func backoffRand(seed int64, url string) *rand.Rand {
+	if seed == 0 {
+		return rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(url))
+	return rand.New(rand.NewSource(seed ^ int64(h.Sum64())))
+}
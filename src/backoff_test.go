@@ -0,0 +1,130 @@
+package src
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// delays returns the delays a strategy produces for the first n retries
+func delays(fn backoffFunc, seed int64, n int) []time.Duration {
+	s := &backoffState{
+		Start: 100 * time.Millisecond,
+		Max:   time.Second,
+		Rand:  backoffRand(seed, "https://example.com"),
+	}
+	var ds []time.Duration
+	for attempt := 1; attempt <= n; attempt++ {
+		ds = append(ds, s.next(fn, attempt))
+	}
+	return ds
+}
+
+func TestBackoff_Exponential(t *testing.T) {
+	t.Parallel()
+	ds := delays(exponentialBackoff, 1, 6)
+
+	// The delay doubles up to the cap, plus up to 100ms of jitter
+	for i, base := range []time.Duration{100, 200, 400, 800} {
+		assert.GreaterOrEqual(t, ds[i], base*time.Millisecond)
+		assert.Less(t, ds[i], (base+100)*time.Millisecond)
+	}
+	assert.Equal(t, time.Second, ds[4])
+	assert.Equal(t, time.Second, ds[5])
+}
+
+func TestBackoff_FullJitter(t *testing.T) {
+	t.Parallel()
+	for i, d := range delays(fullJitterBackoff, 1, 6) {
+		ceiling := min(100*time.Millisecond<<i, time.Second)
+		assert.GreaterOrEqual(t, d, time.Duration(0))
+		assert.LessOrEqual(t, d, ceiling)
+	}
+}
+
+func TestBackoff_DecorrelatedJitter(t *testing.T) {
+	t.Parallel()
+	prev := 100 * time.Millisecond
+	for _, d := range delays(decorrelatedJitterBackoff, 1, 10) {
+		assert.GreaterOrEqual(t, d, 100*time.Millisecond)
+		assert.LessOrEqual(t, d, min(3*prev, time.Second))
+		prev = d
+	}
+}
+
+func TestBackoff_Constant(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t,
+		[]time.Duration{100 * time.Millisecond, 100 * time.Millisecond},
+		delays(constantBackoff, 1, 2),
+	)
+}
+
+func TestBackoff_Growth(t *testing.T) {
+	t.Parallel()
+	s := &backoffState{Start: time.Second, Max: time.Hour}
+
+	// Large attempt numbers don't overflow
+	assert.Equal(t, time.Hour, s.growth(1000))
+}
+
+func TestBackoffRand_Seed(t *testing.T) {
+	t.Parallel()
+	for name, fn := range backoffStrategies {
+		assert.Equal(t, delays(fn, 42, 5), delays(fn, 42, 5), name)
+	}
+
+	a := backoffRand(42, "https://a.com").Int63()
+	assert.Equal(t, a, backoffRand(42, "https://a.com").Int63())
+	assert.NotEqual(t, a, backoffRand(42, "https://b.com").Int63())
+	assert.NotEqual(t, a, backoffRand(43, "https://a.com").Int63())
+}
+
+func TestCheckLinkWith_Attempts(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}),
+	)
+	defer ts.Close()
+
+	cfg := requestConfig{
+		Timeout:      time.Second,
+		MaxRetries:   3,
+		Method:       methodGet,
+		StartBackoff: time.Millisecond,
+		MaxBackoff:   20 * time.Millisecond,
+		Backoff:      fullJitterBackoff,
+		Seed:         7,
+	}
+	lr := checkLinkWith(ts.URL, cfg)
+	require.Len(t, lr.Attempts, 3)
+
+	for i, a := range lr.Attempts {
+		assert.Equal(t, i+1, a.Attempt)
+		assert.Equal(t, http.StatusBadGateway, a.StatusCode)
+		assert.Greater(t, a.DurationMs, 0.0)
+	}
+	assert.Zero(t, lr.Attempts[2].BackoffMs, "no wait after the last attempt")
+
+	// The same seed gives the same delays
+	again := checkLinkWith(ts.URL, cfg)
+	assert.Equal(t, lr.Attempts[0].BackoffMs, again.Attempts[0].BackoffMs)
+	assert.Equal(t, lr.Attempts[1].BackoffMs, again.Attempts[1].BackoffMs)
+}
+
+func TestCheckLinkWith_AttemptError(t *testing.T) {
+	t.Parallel()
+	lr := checkLinkWith("http://localhost:12345", requestConfig{
+		Timeout:    time.Second,
+		MaxRetries: 1,
+	})
+	require.Len(t, lr.Attempts, 1)
+	assert.Contains(t, lr.Attempts[0].Error, "connection refused")
+	assert.Zero(t, lr.Attempts[0].StatusCode)
+}
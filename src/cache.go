@@ -0,0 +1,94 @@
+package src
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// normalizeURL returns the canonical form of a URL used as the cache key.
+// Scheme and host are lowercased, default ports are dropped and the fragment
+// is stripped since it's never sent to the server. Unparsable URLs are
+// returned as is.
+func normalizeURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
+
+// cacheEntry holds the result of a single URL check. The done channel is
+// closed once the record is available.
+type cacheEntry struct {
+	done   chan struct{}
+	record linkRecord
+}
+
+// linkCache memoizes link records for the whole run. HTTP links are keyed by
+// their normalized URL and local links by the path they resolve to. It's
+// safe for concurrent use.
+type linkCache struct {
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+func newLinkCache() *linkCache {
+	return &linkCache{entries: make(map[string]*cacheEntry)}
+}
+
+// check returns the record for key, calling fn only for the first caller of
+// each key. Concurrent callers for the same key wait for that first check to
+// finish instead of issuing their own request.
+func (c *linkCache) check(key string, fn func() linkRecord) linkRecord {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &cacheEntry{done: make(chan struct{})}
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		entry.record = fn()
+		close(entry.done)
+	}
+	<-entry.done
+
+	return entry.record
+}
+
+// records returns the records of the checks that are done, one per key.
+func (c *linkCache) records() []linkRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var records []linkRecord
+	for _, entry := range c.entries {
+		select {
+		case <-entry.done:
+			records = append(records, entry.record)
+		default:
+		}
+	}
+	return records
+}
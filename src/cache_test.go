@@ -0,0 +1,107 @@
+package src
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"https://example.com/a", "https://example.com/a"},
+		{"HTTPS://Example.COM/a", "https://example.com/a"},
+		{"https://example.com:443/a", "https://example.com/a"},
+		{"http://example.com:80/a", "http://example.com/a"},
+		{"http://example.com:8080/a", "http://example.com:8080/a"},
+		{"https://example.com:80/a", "https://example.com:80/a"},
+		{"https://example.com/a#section", "https://example.com/a"},
+		{"https://example.com/A?q=1#x", "https://example.com/A?q=1"},
+		{"http://[::1]:80/a", "http://[::1]/a"},
+		{":%", ":%"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			assert.Equal(t, tt.want, normalizeURL(tt.raw))
+		})
+	}
+}
+
+func TestLinkCache_ChecksOnce(t *testing.T) {
+	t.Parallel()
+	cache := newLinkCache()
+
+	var calls atomic.Int32
+	fn := func() linkRecord {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return linkRecord{StatusCode: http.StatusOK, OK: true}
+	}
+
+	urls := []string{
+		"https://example.com/a",
+		"https://EXAMPLE.com/a#one",
+		"https://example.com:443/a#two",
+	}
+
+	var wg sync.WaitGroup
+	records := make([]linkRecord, len(urls))
+	for i, url := range urls {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			records[i] = cache.check(normalizeURL(url), fn)
+		}(i, url)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for _, record := range records {
+		assert.True(t, record.OK)
+	}
+}
+
+func TestChecker_SharesResultsAcrossFiles(t *testing.T) {
+	t.Parallel()
+	var hits atomic.Int32
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	defer ts.Close()
+
+	c := newChecker(options{
+		Timeout:      time.Second,
+		MaxRetries:   1,
+		StartBackoff: time.Millisecond,
+		MaxBackoff:   time.Millisecond,
+	})
+	defer c.close()
+
+	var buf bytes.Buffer
+	upper := strings.Replace(ts.URL, "http://", "HTTP://", 1)
+	files := [][]string{
+		{ts.URL + "/page", ts.URL + "/page#intro"},
+		{upper + "/page", ts.URL + "/other"},
+	}
+	for _, urls := range files {
+		require.NoError(t, checkLinks(&buf, linksOf(urls...), c, severityError))
+	}
+
+	assert.Equal(t, int32(2), hits.Load())
+	assert.Contains(t, buf.String(), "Location   : "+upper+"/page\n")
+}
@@ -1,11 +1,9 @@
 package src
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"math/rand"
 	"net"
 	"net/http"
 	"os"
@@ -15,9 +13,6 @@ import (
 	"time"
 
 	"github.com/urfave/cli/v2"
-	"github.com/yuin/goldmark"
-	"github.com/yuin/goldmark/ast"
-	"github.com/yuin/goldmark/text"
 )
 
 // readMarkdown reads a markdown file from the provided filepath.
@@ -36,49 +31,31 @@ func readMarkdown(filepath string) ([]byte, error) {
 	return file, nil
 }
 
-// findLinks parses markdown content and returns a slice of found HTTP/S URLs.
-func findLinks(markdown []byte) ([]string, error) {
-	var links []string
+// linkTarget tells whether a link points to a web page or a local file.
+type linkTarget string
 
-	// Parse the markdown.
-	reader := text.NewReader(markdown)
-	parser := goldmark.DefaultParser()
-	document := parser.Parse(reader)
-
-	// Add link to result if it's an HTTP/S URL.
-	addLinkIfHTTP := func(destination []byte) {
-		url := string(destination)
-		if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
-			links = append(links, url)
-		}
-	}
-
-	// Walk AST to find link and image nodes.
-	if err := ast.Walk(
-		document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
-			if entering {
-				switch n := node.(type) {
-				case *ast.Link:
-					addLinkIfHTTP(n.Destination)
-				case *ast.Image:
-					addLinkIfHTTP(n.Destination)
-				}
-			}
-			return ast.WalkContinue, nil
-		}); err != nil {
-		return nil, fmt.Errorf("failed to traverse markdown AST: %w", err)
-	}
-
-	return links, nil
-}
+const (
+	targetHTTP linkTarget = "http"
+	targetFile linkTarget = "file"
+)
 
-// linkRecord stores the result of checking a URL.
+// linkRecord stores the result of checking a URL and where it's referenced.
 type linkRecord struct {
-	Location   string `json:"location"`
-	StatusCode int    `json:"statusCode"`
-	OK         bool   `json:"ok"`
-	Message    string `json:"message"`
-	Attempt    int    `json:"attempt"`
+	Location    string           `json:"location"`
+	Target      linkTarget       `json:"target,omitempty"`
+	Method      string           `json:"method,omitempty"`
+	StatusCode  int              `json:"statusCode"`
+	OK          bool             `json:"ok"`
+	Severity    severity         `json:"severity,omitempty"`
+	Skipped     bool             `json:"skipped,omitempty"`
+	Throttled   bool             `json:"throttled,omitempty"`
+	Soft404     bool             `json:"soft404,omitempty"`
+	Message     string           `json:"message"`
+	Warning     string           `json:"warning,omitempty"`
+	Redirects   []redirectHop    `json:"redirects,omitempty"`
+	Attempt     int              `json:"attempt"`
+	Attempts    []attemptRecord  `json:"attempts,omitempty"`
+	Occurrences []linkOccurrence `json:"occurrences,omitempty"`
 }
 
 func checkLink(
@@ -88,47 +65,201 @@ func checkLink(
 	startBackoff time.Duration,
 	maxBackoff time.Duration,
 ) linkRecord {
+	return checkLinkWith(url, requestConfig{
+		Timeout:      timeout,
+		MaxRetries:   maxRetries,
+		StartBackoff: startBackoff,
+		MaxBackoff:   maxBackoff,
+		MaxRedirects: defaultMaxRedirects,
+	})
+}
+
+// requestConfig holds the settings for checking a single URL.
+type requestConfig struct {
+	Timeout      time.Duration
+	MaxRetries   int
+	StartBackoff time.Duration
+	MaxBackoff   time.Duration
+
+	// Method is methodHead or methodGet, HEAD first when empty.
+	// MaxRedirects caps the redirects followed, 0 doesn't follow any.
+	Method       string
+	MaxRedirects int
+
+	// Backoff computes the delay between retries, exponential by default.
+	// A non zero Seed makes its jitter reproducible. Retry decides which
+	// failures are retried, the default policy when nil.
+	Backoff backoffFunc
+	Seed    int64
+	Retry   *retryPolicy
+
+	// MaxRetryAfter is the longest Retry-After that's waited for, 0 means
+	// any. Throttle coordinates the wait across requests to the same host.
+	MaxRetryAfter time.Duration
+	Throttle      *hostThrottle
+
+	// Accept decides which status codes are alive, those below 400 when
+	// nil.
+	Accept *acceptPolicy
+
+	// Headers are added to every request, Go's defaults are sent when nil.
+	Headers *requestHeaders
+}
+
+// attemptRecord stores the outcome and timing of a single request. An
+// attempt that falls back from HEAD to GET has a record for each request.
+// Backoff is the wait before the next attempt, if any.
+type attemptRecord struct {
+	Attempt    int     `json:"attempt"`
+	Method     string  `json:"method,omitempty"`
+	StatusCode int     `json:"statusCode"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"durationMs"`
+	BackoffMs  float64 `json:"backoffMs,omitempty"`
+}
+
+// milliseconds converts d to milliseconds with microsecond precision.
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// isThrottled reports whether the response asks the client to slow down.
+// A 503 only counts when it comes with a Retry-After header.
+func isThrottled(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode == http.StatusServiceUnavailable &&
+			resp.Header.Get("Retry-After") != ""
+}
+
+// checkLinkWith checks url, retrying failed requests. Once a HEAD request
+// has failed, the remaining attempts go straight to GET. Throttled responses
+// (429, or 503 with Retry-After) pause every request to the host for as
+// long as the server asks. If the retries run out while throttled, the
+// record is marked as throttled rather than as a dead link. The accept
+// policy decides which status codes are alive. Redirects are recorded hop
+// by hop and links that are permanently redirected get a warning.
+func checkLinkWith(url string, cfg requestConfig) linkRecord {
 	client := &http.Client{
-		Timeout: timeout,
+		Timeout:       cfg.Timeout,
+		CheckRedirect: followRedirects(cfg.MaxRedirects),
+		Transport:     cfg.Headers.transport(),
+	}
+	host := hostOf(url)
+
+	strategy := cfg.Backoff
+	if strategy == nil {
+		strategy = exponentialBackoff
+	}
+	backoff := &backoffState{
+		Start: cfg.StartBackoff,
+		Max:   cfg.MaxBackoff,
+		Rand:  backoffRand(cfg.Seed, url),
 	}
 
 	var resp *http.Response
 	var err error
+	var method string
+	var redirects []redirectHop
+	var attempts []attemptRecord
+	var tried int
+	headFirst := cfg.Method != methodGet
 
 	// This should be synchronous, retrying concurrently doesn't make sense.
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		resp, err = client.Get(url)
-		if err == nil && resp.StatusCode < 400 {
-			defer resp.Body.Close()
-			return linkRecord{
-				Location:   url,
-				StatusCode: resp.StatusCode,
-				OK:         true,
-				Message:    http.StatusText(resp.StatusCode),
-				Attempt:    attempt,
+	for attempt := 1; attempt <= cfg.MaxRetries; attempt++ {
+		cfg.Throttle.wait(host)
+		var sent []attemptRecord
+		resp, sent, err = fetchStatus(client, url, headFirst)
+		method = sent[len(sent)-1].Method
+		headFirst = headFirst && method == http.MethodHead
+		redirects = redirectChain(resp)
+		if err != nil {
+			resp = nil
+		}
+
+		// A HEAD that falls back to GET takes two requests in one attempt.
+		for i := range sent {
+			sent[i].Attempt = attempt
+		}
+		attempts = append(attempts, sent...)
+		tried = attempt
+
+		if err == nil {
+			if sev, ok := cfg.Accept.accepts(resp.StatusCode); ok {
+				record := linkRecord{
+					Location:   url,
+					Target:     targetHTTP,
+					Method:     method,
+					StatusCode: resp.StatusCode,
+					OK:         true,
+					Message:    acceptedMessage(resp.StatusCode),
+					Warning:    redirectWarning(redirects),
+					Redirects:  redirects,
+					Attempt:    attempt,
+					Attempts:   attempts,
+				}
+				if sev == severityWarning && record.Warning == "" {
+					record.Warning = fmt.Sprintf(
+						"Status %d is accepted as a warning", resp.StatusCode,
+					)
+				}
+				return record
 			}
 		}
+		retryable := cfg.Retry.retryable(resp, err)
+		if err == nil && isThrottled(resp) {
+			retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			if !ok {
+				retryAfter = backoff.next(strategy, attempt)
+			}
 
-		if attempt < maxRetries {
-			backoff := startBackoff * 2
+			var message string
+			switch {
+			case cfg.MaxRetryAfter > 0 && retryAfter > cfg.MaxRetryAfter:
+				message = fmt.Sprintf(
+					"Throttled, Retry-After of %s exceeds the maximum of %s",
+					retryAfter, cfg.MaxRetryAfter,
+				)
+			case !retryable || attempt == cfg.MaxRetries:
+				message = "Throttled, gave up after " + plural(attempt, "attempt")
+			}
+			if message != "" {
+				// The other requests to the host still back off, for no
+				// longer than the maximum.
+				if cfg.MaxRetryAfter > 0 {
+					retryAfter = min(retryAfter, cfg.MaxRetryAfter)
+				}
+				cfg.Throttle.pause(host, retryAfter)
 
-			// Apply jitter by adding a random amount of milliseconds
-			jitter := time.Duration(rand.Intn(100)) * time.Millisecond
-			actualBackoff := backoff + jitter
+				record := throttledRecord(url, resp.StatusCode, attempt, message)
+				record.Method = method
+				record.Attempts = attempts
+				return record
+			}
 
-			// Cap the backoff time to a maximum value
-			if actualBackoff > maxBackoff {
-				actualBackoff = maxBackoff
+			attempts[len(attempts)-1].BackoffMs = milliseconds(retryAfter)
+			if cfg.Throttle != nil {
+				cfg.Throttle.pause(host, retryAfter)
+			} else {
+				time.Sleep(retryAfter)
 			}
+			continue
+		}
 
-			time.Sleep(actualBackoff)
+		// Failures like 404 or a certificate error won't go away on retry.
+		if !retryable {
+			break
+		}
+		if attempt < cfg.MaxRetries {
+			delay := backoff.next(strategy, attempt)
+			attempts[len(attempts)-1].BackoffMs = milliseconds(delay)
+			time.Sleep(delay)
 		}
 	}
 
 	statusText := "Unknown error"
 	if err != nil {
 		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			statusText = "Request timed out after " + timeout.String()
+			statusText = "Request timed out after " + cfg.Timeout.String()
 		} else {
 			statusText = err.Error()
 		}
@@ -143,28 +274,207 @@ func checkLink(
 
 	return linkRecord{
 		Location:   url,
+		Target:     targetHTTP,
+		Method:     method,
 		StatusCode: statusCode,
 		OK:         false,
 		Message:    statusText,
-		Attempt:    maxRetries,
+		Redirects:  redirects,
+		Attempt:    tried,
+		Attempts:   attempts,
 	}
 }
 
-// printFilepath prints the filepath unless outputting JSON.
-func printFilepath(w io.Writer, filepath string, asJSON bool) {
-	if !asJSON {
-		fmt.Fprintf(w, "Filepath: %s\n\n", filepath)
+// throttledRecord returns the record of a URL whose host kept throttling
+// requests until the retries ran out.
+func throttledRecord(url string, statusCode, attempt int, message string) linkRecord {
+	return linkRecord{
+		Location:   url,
+		Target:     targetHTTP,
+		StatusCode: statusCode,
+		OK:         false,
+		Throttled:  true,
+		Message:    message,
+		Attempt:    attempt,
 	}
 }
 
-// printLinkRecordJSON encodes a linkRecord to JSON.
-func printLinkRecordJSON(w io.Writer, lr linkRecord) error {
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
+// checker checks URLs with the settings of a run. Results are shared through
+// a cache so that every unique URL is requested once, no matter how many
+// files or lines reference it. Checks run on a bounded worker pool and the
+// number of in-flight requests per host is capped.
+type checker struct {
+	timeout      time.Duration
+	maxRetries   int
+	startBackoff time.Duration
+	maxBackoff   time.Duration
+	method       string
+	maxRedirects int
+	backoff      backoffFunc
+	seed         int64
+	retry        *retryPolicy
+	retryAfter   time.Duration
+	throttle     *hostThrottle
+	accept       *acceptPolicy
+	headers      *requestHeaders
+	root         string
+	hostConfigs  map[string]hostConfig
+	anchors      *anchorIndex
+	pages        *pageIndex
+	soft404      *soft404Detector
+	report       reporter
+	stats        *runStats
+	cache        *linkCache
+	pool         *workerPool
+	hosts        *hostLimiter
+}
+
+// newChecker creates a checker for opts. The caller must close it to stop
+// the worker pool.
+func newChecker(opts options) *checker {
+	var pages *pageIndex
+	if opts.RemoteAnchors {
+		pages = newPageIndex(opts.Timeout, opts.Headers.transport())
+	}
 
-	// Convert to camelCase.
-	// ...
-	return enc.Encode(lr)
+	concurrency := max(opts.Concurrency, 1)
+	return &checker{
+		timeout:      opts.Timeout,
+		maxRetries:   opts.MaxRetries,
+		startBackoff: opts.StartBackoff,
+		maxBackoff:   opts.MaxBackoff,
+		method:       opts.Method,
+		maxRedirects: opts.MaxRedirects,
+		backoff:      backoffStrategies[opts.Backoff],
+		seed:         opts.BackoffSeed,
+		retry:        opts.RetryOn,
+		retryAfter:   opts.MaxRetryAfter,
+		throttle:     newHostThrottle(),
+		accept:       opts.Accept,
+		headers:      opts.Headers,
+		root:         opts.Root,
+		hostConfigs:  opts.Hosts,
+		anchors:      newAnchorIndex(slugAlgorithms[opts.Slug]),
+		pages:        pages,
+		soft404:      opts.Soft404,
+		report:       opts.Report,
+		stats:        newRunStats(),
+		cache:        newLinkCache(),
+		pool:         newWorkerPool(concurrency, concurrency),
+		hosts:        newHostLimiter(opts.HostConcurrency),
+	}
+}
+
+// check returns the linkRecord for url, reusing a previous result if the
+// same URL has already been checked during this run.
+func (c *checker) check(url string) linkRecord {
+	record := c.cache.check(normalizeURL(url), func() linkRecord {
+		host := hostOf(url)
+		release := c.acquire(host)
+		defer release()
+
+		cfg := requestConfig{
+			Timeout:       c.timeout,
+			MaxRetries:    c.maxRetries,
+			StartBackoff:  c.startBackoff,
+			MaxBackoff:    c.maxBackoff,
+			Method:        c.method,
+			MaxRedirects:  c.maxRedirects,
+			Backoff:       c.backoff,
+			Seed:          c.seed,
+			Retry:         c.retry,
+			MaxRetryAfter: c.retryAfter,
+			Throttle:      c.throttle,
+			Accept:        c.accept,
+			Headers:       c.headers,
+		}
+		settings := hostSettings(c.hostConfigs, host)
+		if settings.Timeout > 0 {
+			cfg.Timeout = settings.Timeout
+		}
+		if settings.MaxRetries > 0 {
+			cfg.MaxRetries = settings.MaxRetries
+		}
+		if settings.Accept != nil {
+			cfg.Accept = settings.Accept
+		}
+
+		record := checkLinkWith(url, cfg)
+		if c.soft404 != nil && record.OK {
+			if reason := c.soft404.detect(url); reason != "" {
+				record.Soft404 = true
+				record.Warning = reason
+			}
+		}
+		return record
+	})
+
+	// The cached record may come from the same URL with another fragment.
+	record.Location = url
+	if len(record.Redirects) > 0 {
+		record.Redirects = append([]redirectHop{{url, record.Redirects[0].StatusCode}},
+			record.Redirects[1:]...)
+	}
+
+	if c.pages != nil && record.OK {
+		if fragment := remoteFragment(url); fragment != "" {
+			record = c.checkFragment(record, fragment)
+		}
+	}
+	return record
+}
+
+// acquire takes a request slot for host, honoring its configured concurrency.
+func (c *checker) acquire(host string) func() {
+	if settings := hostSettings(c.hostConfigs, host); settings.Concurrency > 0 {
+		return c.hosts.acquireLimit(host, settings.Concurrency)
+	}
+	return c.hosts.acquire(host)
+}
+
+// unverifiedAnchor starts the message of links whose page couldn't be
+// fetched to look for the anchor.
+const unverifiedAnchor = "Page OK, couldn't verify anchor"
+
+// checkFragment verifies that the page of a record that's OK has an anchor
+// matching the fragment of its URL.
+func (c *checker) checkFragment(record linkRecord, fragment string) linkRecord {
+	release := c.acquire(hostOf(record.Location))
+	anchors, err := c.pages.lookup(record.Location)
+	release()
+
+	switch {
+	case err != nil:
+		record.Severity = severityInfo
+		record.Message = fmt.Sprintf("%s #%s: %s", unverifiedAnchor, fragment, err)
+	case anchors != nil && !hasRemoteAnchor(anchors, fragment):
+		record.OK = false
+		record.Message = fmt.Sprintf("Page OK, anchor #%s not found", fragment)
+	}
+	return record
+}
+
+// checkLocal returns the linkRecord for a local link found in the file at
+// from. Links are cached by the path they resolve to and their fragment.
+func (c *checker) checkLocal(link, from string) linkRecord {
+	_, fragment := splitFragment(link)
+	key := resolveLocal(link, from, c.root) + "#" + fragment
+
+	record := c.cache.check(key, func() linkRecord {
+		return checkLocalLink(link, from, c.root, c.anchors)
+	})
+	record.Location = link
+	return record
+}
+
+// close waits for pending checks and stops the worker pool.
+func (c *checker) close() {
+	c.pool.close()
+}
+
+// printFilepath prints the filepath.
+func printFilepath(w io.Writer, filepath string) {
+	fmt.Fprintf(w, "Filepath: %s\n\n", filepath)
 }
 
 // printLinkRecordTab prints a linkRecord in tabular format.
@@ -172,9 +482,18 @@ func printLinkRecordTab(w io.Writer, lr linkRecord) error {
 	tpl := `- Location   : {{.Location}}
   Status Code: {{if eq .StatusCode 0}}-{{else}}{{.StatusCode}}{{end}}
   OK         : {{.OK}}
-  Message    : {{if .Message}}{{.Message}}{{else}}-{{end}}
-  Attempt    : {{.Attempt}}
-
+{{if .Severity}}  Severity   : {{.Severity}}
+{{end}}{{if .Skipped}}  Skipped    : true
+{{end}}{{if .Throttled}}  Throttled  : true
+{{end}}{{if .Soft404}}  Soft 404   : true
+{{end}}  Message    : {{if .Message}}{{.Message}}{{else}}-{{end}}
+{{if .Warning}}  Warning    : {{.Warning}}
+{{end}}{{range $i, $h := .Redirects -}}
+{{if $i}}              {{else}}  Redirects  :{{end}} {{$h}}
+{{end}}  Attempt    : {{.Attempt}}
+{{range $i, $o := .Occurrences -}}
+{{if $i}}              {{else}}  Position   :{{end}} {{$o}}
+{{end}}
 `
 	t, err := template.New("record").Parse(tpl)
 	if err != nil {
@@ -187,93 +506,294 @@ func printLinkRecordTab(w io.Writer, lr linkRecord) error {
 	return nil
 }
 
-// printLinkRecord prints a linkRecord in JSON or tabular format.
-func printLinkRecord(w io.Writer, lr linkRecord, asJSON bool) error {
-	if asJSON {
-		return printLinkRecordJSON(w, lr)
+// groupLinks groups link occurrences by URL, keeping the order in which
+// each URL first appears.
+func groupLinks(links []linkOccurrence) ([]string, map[string][]linkOccurrence) {
+	var urls []string
+	groups := make(map[string][]linkOccurrence)
+	for _, link := range links {
+		if _, ok := groups[link.URL]; !ok {
+			urls = append(urls, link.URL)
+		}
+		groups[link.URL] = append(groups[link.URL], link)
 	}
-	return printLinkRecordTab(w, lr)
+	return urls, groups
 }
 
-// checkLinks concurrently checks a list of URLs.
-// Prints results and returns first error encountered, if any.
-func checkLinks(
-	w io.Writer,
-	urls []string,
-	timeout time.Duration,
-	maxRetries int,
-	startBackoff time.Duration,
-	maxBackoff time.Duration,
-	errOK bool,
-	asJSON bool,
-) error {
+// printSkipped prints the records of the skipped URLs.
+func printSkipped(w io.Writer, records []linkRecord) error {
+	for _, record := range records {
+		if err := printLinkRecordTab(w, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skippedRecords returns a record for every unique URL that was skipped,
+// with the reason of its first occurrence.
+func skippedRecords(links []linkOccurrence, filter *linkFilter) []linkRecord {
+	var records []linkRecord
+	urls, groups := groupLinks(links)
+	for _, url := range urls {
+		target := targetFile
+		if isHTTP(url) {
+			target = targetHTTP
+		}
+		reason := groups[url][0].SkipReason
+		if reason == "" {
+			reason = filter.skipReason(url)
+		}
+		record := skippedRecord(url, target, reason)
+		record.Occurrences = groups[url]
+		records = append(records, record)
+	}
+	return records
+}
+
+// checkLinks concurrently checks the unique URLs of a list of links on the
+// checker's pool. Prints results and returns the error of the first one
+// whose severity reaches failOn, if any.
+func checkLinks(w io.Writer, links []linkOccurrence, c *checker, failOn severity) error {
 	var (
 		wg    sync.WaitGroup
 		mutex sync.Mutex
 		err   error
 	)
 
+	if failOn == "" {
+		failOn = severityError
+	}
+
+	urls, groups := groupLinks(links)
 	for _, url := range urls {
 		wg.Add(1)
 
-		go func(url string) {
+		c.pool.submit(func() {
 			defer wg.Done()
 
-			result := checkLink(url, timeout, maxRetries, startBackoff, maxBackoff)
+			var result linkRecord
+			if isHTTP(url) {
+				result = c.check(url)
+			} else {
+				result = c.checkLocal(url, groups[url][0].Filepath)
+			}
+			result.Occurrences = groups[url]
+			result.Severity = classify(result)
+			c.stats.add(result)
 
 			mutex.Lock()
 			defer mutex.Unlock()
 
-			if printErr := printLinkRecord(w, result, asJSON); printErr != nil {
+			var printErr error
+			if c.report != nil {
+				printErr = c.report.record(w, result)
+			} else {
+				printErr = printLinkRecordTab(w, result)
+			}
+			if printErr != nil {
 				err = printErr
 				return
 			}
 
-			if result.StatusCode >= 400 && err == nil {
-				err = errors.New("one or more URLs have error status codes")
+			if err == nil && result.Severity.atLeast(failOn) {
+				err = failure(result)
 			}
-		}(url)
+		})
 	}
 
 	wg.Wait()
+	return err
+}
+
+// options holds the settings of a single run.
+type options struct {
+	Paths        []string
+	IncludePaths []string
+	ExcludePaths []string
+	Timeout      time.Duration
+	MaxRetries   int
+	StartBackoff time.Duration
+	MaxBackoff   time.Duration
+
+	// Report receives the results in place of the tabular output when it
+	// isn't nil.
+	Report reporter
+
+	// FailOn is the lowest severity that fails the run, errors when empty.
+	FailOn severity
 
-	if err != nil && !errOK {
-		return err
+	// Method is methodHead to try HEAD before GET, or methodGet.
+	// MaxRedirects caps the redirects followed for each request.
+	Method       string
+	MaxRedirects int
+
+	// Backoff names the strategy that spaces out retries and BackoffSeed
+	// makes its jitter reproducible when it isn't 0.
+	Backoff     string
+	BackoffSeed int64
+
+	// RetryOn decides which failures are retried, nil means the default
+	// policy.
+	RetryOn *retryPolicy
+
+	// MaxRetryAfter is the longest Retry-After a throttled request waits
+	// for before giving up.
+	MaxRetryAfter time.Duration
+
+	// Accept decides which status codes count as alive on top of those
+	// below 400, nil means only those.
+	Accept *acceptPolicy
+
+	// Headers are sent with every request, along with the User-Agent and
+	// the headers configured for the host.
+	Headers *requestHeaders
+
+	// Concurrency is the number of URLs checked at the same time and
+	// HostConcurrency caps the in-flight requests to a single host.
+	Concurrency     int
+	HostConcurrency int
+
+	// Root is the directory that absolute local links resolve against and
+	// Slug names the algorithm that turns headings into anchors.
+	Root string
+	Slug string
+
+	// RemoteAnchors enables checking the fragments of HTTP links against
+	// the anchors of the fetched page.
+	RemoteAnchors bool
+
+	// Soft404 flags pages that answer 200 but look like a missing page, nil
+	// disables the detection.
+	Soft404 *soft404Detector
+
+	// Filter decides which links are checked and ShowSkipped prints a record
+	// for the links it skips.
+	Filter      *linkFilter
+	ShowSkipped bool
+
+	// ReportUnusedDirectives prints the inline directives that don't
+	// disable any link.
+	ReportUnusedDirectives bool
+
+	// Hosts maps host names or glob patterns to settings that override the
+	// global ones. They can only be set in the config file.
+	Hosts map[string]hostConfig
+}
+
+// checkFile reads a single markdown file, then checks and prints its links.
+func checkFile(w io.Writer, filepath string, c *checker, opts options) error {
+	c.stats.addFile()
+	if opts.Report != nil {
+		if err := opts.Report.beginFile(w, filepath); err != nil {
+			return err
+		}
+	} else {
+		printFilepath(w, filepath)
 	}
 
-	return nil
+	markdown, err := readMarkdown(filepath)
+	if err != nil {
+		return reportFileError(w, filepath, err, c, opts)
+	}
+
+	links, directives, err := findLinks(filepath, markdown)
+	if err != nil {
+		return reportFileError(w, filepath, err, c, opts)
+	}
+
+	if opts.ReportUnusedDirectives {
+		for _, d := range unusedDirectives(directives) {
+			c.stats.addDirective()
+			var err error
+			if opts.Report != nil {
+				err = opts.Report.directive(w, d)
+			} else {
+				err = printUnusedDirective(w, d)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Reporters get the skipped links whether they're shown or not, and
+	// decide for themselves.
+	links, skipped := opts.Filter.split(links)
+	records := skippedRecords(skipped, opts.Filter)
+	c.stats.add(records...)
+	if opts.Report != nil {
+		for _, record := range records {
+			if err := opts.Report.record(w, record); err != nil {
+				return err
+			}
+		}
+	} else if opts.ShowSkipped {
+		if err := printSkipped(w, records); err != nil {
+			return err
+		}
+	}
+
+	return checkLinks(w, links, c, opts.FailOn)
 }
 
-// orchestrate coordinates the full link checking process.
-func orchestrate(
+// reportFileError counts a file that couldn't be checked, hands the error to
+// the reporter, if any, and returns it.
+func reportFileError(
 	w io.Writer,
 	filepath string,
-	timeout time.Duration,
-	maxRetries int,
-	startBackoff time.Duration,
-	maxBackoff time.Duration,
-	errOK bool,
-	asJSON bool,
-	exitFunc func(int),
-) {
-	printFilepath(w, filepath, asJSON)
+	err error,
+	c *checker,
+	opts options,
+) error {
+	c.stats.addFileError()
+	if opts.Report != nil {
+		if reportErr := opts.Report.fileError(w, filepath, err); reportErr != nil {
+			return reportErr
+		}
+	}
+	return err
+}
 
-	markdown, err := readMarkdown(filepath)
+// orchestrate coordinates the full link checking process across every
+// file matched by the provided paths. All files are checked before exiting
+// so that the exit code covers the whole run, and a URL referenced from
+// several files is only requested once.
+func orchestrate(w io.Writer, opts options, exitFunc func(int)) {
+	files, err := collectFiles(opts.Paths, opts.IncludePaths, opts.ExcludePaths)
 	if err != nil {
 		fmt.Fprintln(w, err)
 		exitFunc(1)
+		return
+	}
+
+	c := newChecker(opts)
+	defer c.close()
+
+	failed := false
+	for _, filepath := range files {
+		if err := checkFile(w, filepath, c, opts); err != nil {
+			if opts.Report == nil {
+				fmt.Fprintln(w, err)
+			}
+			failed = true
+		}
 	}
 
-	links, err := findLinks(markdown)
+	// The summary goes after the last file, reporters put it in their
+	// document.
+	summary := c.stats.finish(c.cache, failed)
+	if opts.Report != nil {
+		err = opts.Report.finish(w, summary)
+	} else {
+		err = printSummary(w, summary)
+	}
 	if err != nil {
 		fmt.Fprintln(w, err)
-		exitFunc(1)
+		failed = true
 	}
 
-	if err := checkLinks(
-		w, links, timeout, maxRetries, startBackoff, maxBackoff, errOK, asJSON,
-	); err != nil {
-		fmt.Fprintln(w, err)
+	if failed {
 		exitFunc(1)
 	}
 }
@@ -293,94 +813,393 @@ func CLI(w io.Writer, version string, exitFunc func(int)) {
 	app.Writer = w
 	app.ErrWriter = w
 
-	// Global Flags
-	app.Flags = []cli.Flag{
-		&cli.StringFlag{
+	app.Flags = appFlags()
+	app.Commands = []*cli.Command{fixCommand(w, exitFunc)}
+
+	// Main Action
+	app.Action = func(c *cli.Context) error {
+		opts, err := contextOptions(w, c)
+		if err != nil {
+			return err
+		}
+		orchestrate(w, opts, exitFunc)
+		return nil
+	}
+
+	// Handle execution
+	err := app.Run(os.Args)
+	if err != nil {
+		exitFunc(2)
+	}
+}
+
+// appFlags returns the global flags. The fix command accepts them too, so
+// they can follow the command name.
+func appFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
 			Name:    "filepath",
 			Aliases: []string{"f"},
-			Usage:   "path to the markdown file",
+			EnvVars: envVars("filepath"),
+			Usage:   "path to a markdown file, directory or glob pattern (repeatable)",
+		},
+		&cli.StringSliceFlag{
+			Name:    "include-path",
+			EnvVars: envVars("include-path"),
+			Usage:   "only check files matching these glob patterns",
+		},
+		&cli.StringSliceFlag{
+			Name:    "exclude-path",
+			EnvVars: envVars("exclude-path"),
+			Usage:   "skip files matching these glob patterns",
 		},
 		&cli.DurationFlag{
 			Name:    "timeout",
 			Aliases: []string{"t"},
+			EnvVars: envVars("timeout"),
 			Value:   5 * time.Second,
 			Usage:   "timeout for each HTTP request",
 		},
 		&cli.BoolFlag{
 			Name:    "error-ok",
 			Aliases: []string{"e"},
+			EnvVars: envVars("error-ok"),
 			Value:   false,
-			Usage:   "always exit with code 0",
+			Usage:   "always exit with code 0, same as --fail-on never",
+		},
+		&cli.StringFlag{
+			Name:    "fail-on",
+			EnvVars: envVars("fail-on"),
+			Value:   string(severityError),
+			Usage: "lowest severity that makes the run fail: " +
+				"info, warning, error or never",
 		},
 		&cli.BoolFlag{
 			Name:    "json",
 			Aliases: []string{"j"},
+			EnvVars: envVars("json"),
 			Value:   false,
-			Usage:   "output as JSON",
+			Usage:   "output as JSON, same as --format json",
+		},
+		&cli.StringFlag{
+			Name:    "format",
+			EnvVars: envVars("format"),
+			Value:   formatTab,
+			Usage: "output format: tab, json, ndjson, sarif, junit or github, " +
+				"the default in GitHub Actions",
+		},
+		&cli.StringFlag{
+			Name:    "user-agent",
+			EnvVars: envVars("user-agent"),
+			Usage: "User-Agent header sent with every request, " +
+				"link-patrol/<version> by default",
+		},
+		&cli.StringSliceFlag{
+			Name:    "header",
+			EnvVars: envVars("header"),
+			Usage: `header like "Accept: text/html" sent with every request, ` +
+				"$VARS are expanded",
+		},
+		&cli.StringFlag{
+			Name:    "method",
+			EnvVars: envVars("method"),
+			Value:   methodHead,
+			Usage:   "head tries HEAD first and falls back to GET, get always uses GET",
+		},
+		&cli.IntFlag{
+			Name:    "max-redirects",
+			EnvVars: envVars("max-redirects"),
+			Value:   defaultMaxRedirects,
+			Usage:   "maximum number of redirects followed for each URL, 0 follows none",
 		},
 		&cli.IntFlag{
-			Name:  "max-retries",
-			Value: 1,
-			Usage: "maximum number of retries for each URL",
+			Name:    "max-retries",
+			EnvVars: envVars("max-retries"),
+			Value:   1,
+			Usage:   "maximum number of retries for each URL",
 		},
 		&cli.DurationFlag{
-			Name:  "start-backoff",
-			Value: 1 * time.Second,
-			Usage: "initial backoff duration for retries",
+			Name:    "start-backoff",
+			EnvVars: envVars("start-backoff"),
+			Value:   1 * time.Second,
+			Usage:   "initial backoff duration for retries",
 		},
 		&cli.DurationFlag{
-			Name:  "max-backoff",
-			Value: 4 * time.Second,
-			Usage: "maximum backoff duration for retries",
+			Name:    "max-backoff",
+			EnvVars: envVars("max-backoff"),
+			Value:   4 * time.Second,
+			Usage:   "maximum backoff duration for retries",
+		},
+		&cli.StringFlag{
+			Name:    "backoff",
+			EnvVars: envVars("backoff"),
+			Value:   "exponential",
+			Usage:   "exponential, full-jitter, decorrelated-jitter or constant",
+		},
+		&cli.Int64Flag{
+			Name:    "backoff-seed",
+			EnvVars: envVars("backoff-seed"),
+			Value:   0,
+			Usage:   "seed for the backoff jitter to make delays reproducible, 0 is random",
+		},
+		&cli.StringSliceFlag{
+			Name:    "retry-on",
+			EnvVars: envVars("retry-on"),
+			Value:   cli.NewStringSlice(defaultRetryOn...),
+			Usage: "status codes like 5xx or 500-504, " +
+				"timeout, reset and dns errors to retry",
+		},
+		&cli.StringSliceFlag{
+			Name:    "accept",
+			EnvVars: envVars("accept"),
+			Usage: "status codes like 403 or 4xx that are OK, " +
+				"suffix :warning to warn instead",
+		},
+		&cli.DurationFlag{
+			Name:    "max-retry-after",
+			EnvVars: envVars("max-retry-after"),
+			Value:   time.Minute,
+			Usage:   "longest Retry-After to wait for on 429 and 503, 0 waits for any",
+		},
+		&cli.IntFlag{
+			Name:    "concurrency",
+			Aliases: []string{"c"},
+			EnvVars: envVars("concurrency"),
+			Value:   16,
+			Usage:   "maximum number of URLs checked at the same time",
+		},
+		&cli.IntFlag{
+			Name:    "host-concurrency",
+			EnvVars: envVars("host-concurrency"),
+			Value:   4,
+			Usage:   "maximum number of in-flight requests per host, 0 disables the cap",
+		},
+		&cli.StringFlag{
+			Name:    "root",
+			EnvVars: envVars("root"),
+			Value:   ".",
+			Usage:   "directory that absolute local links like /docs/a.md resolve against",
+		},
+		&cli.StringFlag{
+			Name:    "slug",
+			EnvVars: envVars("slug"),
+			Value:   "github",
+			Usage:   "how headings turn into anchors: github, gitlab or bitbucket",
+		},
+		&cli.BoolFlag{
+			Name:    "remote-anchors",
+			EnvVars: envVars("remote-anchors"),
+			Value:   false,
+			Usage:   "verify that #fragments of HTTP links exist on the fetched page",
+		},
+		&cli.BoolFlag{
+			Name:    "soft-404",
+			EnvVars: envVars("soft-404"),
+			Value:   false,
+			Usage:   "warn about pages that return 200 but look like a missing page",
+		},
+		&cli.StringSliceFlag{
+			Name:    "soft-404-pattern",
+			EnvVars: envVars("soft-404-pattern"),
+			Value:   cli.NewStringSlice(defaultSoft404Patterns...),
+			Usage:   "title or text of a missing page, or a regex prefixed with re:",
+		},
+		&cli.StringSliceFlag{
+			Name:    "include",
+			EnvVars: envVars("include"),
+			Usage:   "only check URLs matching these globs, or regexes prefixed with re:",
+		},
+		&cli.StringSliceFlag{
+			Name:    "exclude",
+			EnvVars: envVars("exclude"),
+			Usage:   "skip URLs matching these globs, or regexes prefixed with re:",
+		},
+		&cli.BoolFlag{
+			Name:    "show-skipped",
+			EnvVars: envVars("show-skipped"),
+			Value:   false,
+			Usage:   "print the links skipped by --include, --exclude or directives",
+		},
+		&cli.BoolFlag{
+			Name:    "report-unused-directives",
+			EnvVars: envVars("report-unused-directives"),
+			Value:   false,
+			Usage:   "print link-patrol-disable comments that don't disable any link",
+		},
+		&cli.StringFlag{
+			Name:    "config",
+			EnvVars: envVars("config"),
+			Usage:   "config file, by default the nearest .link-patrol.yaml or .toml",
 		},
 	}
+}
 
-	// Main Action
-	app.Action = func(c *cli.Context) error {
-		filepath := c.String("filepath")
-		timeout := c.Duration("timeout")
-		maxRetries := c.Int("max-retries")
-		startBackoff := c.Duration("start-backoff")
-		maxBackoff := c.Duration("max-backoff")
-		errOK := c.Bool("error-ok")
-		asJSON := c.Bool("json")
-
-		if filepath == "" {
-			// Show help if no filepath is provided
-			_ = cli.ShowAppHelp(c)
-			return fmt.Errorf("filepath is required")
-		}
+// contextOptions returns the options of the run and prints why they're
+// invalid, if they are.
+func contextOptions(w io.Writer, c *cli.Context) (options, error) {
+	opts, err := readOptions(c)
+	if err != nil {
+		fmt.Fprintln(w, err)
+	}
+	return opts, err
+}
 
-		// startBackoff should be at least 1ms
-		if startBackoff < time.Millisecond {
-			return fmt.Errorf("start-backoff should be at least 1ms")
-		}
+// readOptions validates the flags of c and returns the options of the
+// run. Flags that weren't set are filled in from the config file, if any.
+func readOptions(c *cli.Context) (options, error) {
+	cfg, err := contextConfig(c)
+	if err == nil && cfg != nil {
+		err = applyConfig(c, cfg)
+	}
+	if err != nil {
+		return options{}, err
+	}
+	var hosts map[string]hostConfig
+	if cfg != nil {
+		hosts = cfg.Hosts
+	}
 
-		// maxBackoff must be greater than or equal to startBackoff
-		if maxBackoff < startBackoff {
-			return fmt.Errorf(
-				"max-backoff should be greater than or equal to start-backoff",
-			)
-		}
+	paths := c.StringSlice("filepath")
+	timeout := c.Duration("timeout")
+	maxRetries := c.Int("max-retries")
+	startBackoff := c.Duration("start-backoff")
+	maxBackoff := c.Duration("max-backoff")
+	failOn := severity(c.String("fail-on"))
+	if c.Bool("error-ok") {
+		failOn = failNever
+	}
+	format := c.String("format")
+	switch {
+	case c.Bool("json"):
+		format = formatJSON
+	case !c.IsSet("format") && inGitHubActions():
+		format = formatGitHub
+	}
+
+	if len(paths) == 0 {
+		// Show help if no filepath is provided
+		_ = cli.ShowAppHelp(c)
+		return options{}, fmt.Errorf("filepath is required")
+	}
+
+	// startBackoff should be at least 1ms
+	if startBackoff < time.Millisecond {
+		return options{}, fmt.Errorf("start-backoff should be at least 1ms")
+	}
 
-		// Proceed with orchestration as filepath is provided
-		orchestrate(
-			w,
-			filepath,
-			timeout,
-			maxRetries,
-			startBackoff,
-			maxBackoff,
-			errOK,
-			asJSON,
-			exitFunc,
+	// maxBackoff must be greater than or equal to startBackoff
+	if maxBackoff < startBackoff {
+		return options{}, fmt.Errorf(
+			"max-backoff should be greater than or equal to start-backoff",
 		)
-		return nil
 	}
 
-	// Handle execution
-	err := app.Run(os.Args)
+	// At least one URL has to be checked at a time
+	if c.Int("concurrency") < 1 {
+		return options{}, fmt.Errorf("concurrency should be at least 1")
+	}
+
+	if c.Int("host-concurrency") < 0 {
+		return options{}, fmt.Errorf("host-concurrency should not be negative")
+	}
+
+	if c.Int("max-redirects") < 0 {
+		return options{}, fmt.Errorf("max-redirects should not be negative")
+	}
+
+	if !failOnLevels[failOn] {
+		return options{}, fmt.Errorf("unknown severity %q for fail-on", failOn)
+	}
+
+	if !outputFormats[format] {
+		return options{}, fmt.Errorf("unknown output format %q", format)
+	}
+
+	if !requestMethods[c.String("method")] {
+		return options{}, fmt.Errorf("unknown request method %q", c.String("method"))
+	}
+
+	if _, ok := backoffStrategies[c.String("backoff")]; !ok {
+		return options{}, fmt.Errorf("unknown backoff strategy %q", c.String("backoff"))
+	}
+
+	if _, ok := slugAlgorithms[c.String("slug")]; !ok {
+		return options{}, fmt.Errorf("unknown slug algorithm %q", c.String("slug"))
+	}
+
+	retryOn, err := newRetryPolicy(c.StringSlice("retry-on"))
 	if err != nil {
-		exitFunc(2)
+		return options{}, err
+	}
+
+	accept, err := newAcceptPolicy(c.StringSlice("accept"))
+	if err != nil {
+		return options{}, err
+	}
+
+	filter, err := newLinkFilter(c.StringSlice("include"), c.StringSlice("exclude"))
+	if err != nil {
+		return options{}, err
+	}
+
+	userAgent := c.String("user-agent")
+	if userAgent == "" {
+		userAgent = defaultUserAgent(c.App.Version)
+	}
+	headers, err := newRequestHeaders(userAgent, c.StringSlice("header"), hosts)
+	if err != nil {
+		return options{}, err
+	}
+
+	var soft404 *soft404Detector
+	if c.Bool("soft-404") {
+		soft404, err = newSoft404Detector(
+			timeout, headers.transport(), c.StringSlice("soft-404-pattern"),
+		)
+		if err != nil {
+			return options{}, err
+		}
 	}
+
+	return options{
+		Paths:        paths,
+		IncludePaths: c.StringSlice("include-path"),
+		ExcludePaths: c.StringSlice("exclude-path"),
+		Timeout:      timeout,
+		MaxRetries:   maxRetries,
+		StartBackoff: startBackoff,
+		MaxBackoff:   maxBackoff,
+		FailOn:       failOn,
+		Method:       c.String("method"),
+		MaxRedirects: c.Int("max-redirects"),
+
+		Backoff:       c.String("backoff"),
+		BackoffSeed:   c.Int64("backoff-seed"),
+		RetryOn:       retryOn,
+		MaxRetryAfter: c.Duration("max-retry-after"),
+		Accept:        accept,
+		Headers:       headers,
+
+		Concurrency:     c.Int("concurrency"),
+		HostConcurrency: c.Int("host-concurrency"),
+
+		Root: c.String("root"),
+		Slug: c.String("slug"),
+
+		RemoteAnchors: c.Bool("remote-anchors"),
+		Soft404:       soft404,
+
+		Filter:      filter,
+		ShowSkipped: c.Bool("show-skipped"),
+
+		ReportUnusedDirectives: c.Bool("report-unused-directives"),
+		Report: newReporter(
+			format,
+			c.App.Version,
+			failOn,
+			c.Bool("show-skipped"),
+		),
+
+		Hosts: hosts,
+	}, nil
 }
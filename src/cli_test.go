@@ -2,11 +2,15 @@ package src
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"text/tabwriter"
 	"time"
@@ -15,6 +19,14 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// TestMain keeps the CLI tests from switching to annotations when they run
+// in GitHub Actions.
+func TestMain(m *testing.M) {
+	os.Unsetenv("GITHUB_ACTIONS")
+	os.Unsetenv("GITHUB_STEP_SUMMARY")
+	os.Exit(m.Run())
+}
+
 // TestReadMarkdown tests the readFile function
 func TestReadMarkdown(t *testing.T) {
 	t.Parallel()
@@ -81,108 +93,6 @@ func TestReadMarkdown_NonMarkdownFile(t *testing.T) {
 	require.Error(t, err, "Expected an error for non-markdown file")
 }
 
-func TestFindLinks(t *testing.T) {
-	t.Parallel()
-	tests := []struct {
-		name     string
-		markdown []byte
-		want     []string
-	}{
-		{
-			name: "Basic Functionality",
-			markdown: []byte(
-				"[link](http://example.com) ![image](https://example.com/image.jpg)",
-			),
-			want: []string{
-				"http://example.com",
-				"https://example.com/image.jpg",
-			},
-		},
-		{
-			name:     "No Links",
-			markdown: []byte("No links here."),
-			want:     []string{},
-		},
-		{
-			name: "Mixed Content",
-			markdown: []byte(
-				"# Heading\n\n[link](http://example.com)\n\n" +
-					"Text here\n\n![image](https://example.com/image.jpg)",
-			),
-			want: []string{
-				"http://example.com",
-				"https://example.com/image.jpg",
-			},
-		},
-		{
-			name: "Non-HTTP/S Links",
-			markdown: []byte(
-				`[http](http://example.com) [https](https://example.com)
-				[ftp](ftp://example.com) [mailto](mailto:example@example.com)`,
-			),
-			want: []string{"http://example.com", "https://example.com"},
-		},
-		{
-			name: "Nested Elements",
-			markdown: []byte(
-				"> [link](http://example.com)\n\n* ![image](https://example.com/image.jpg)",
-			),
-			want: []string{
-				"http://example.com",
-				"https://example.com/image.jpg",
-			},
-		},
-		{
-			name:     "Invalid Markdown Syntax",
-			markdown: []byte("[Invalid link](http://example.com"),
-			want:     []string{},
-		},
-		{
-			name: "Large Input",
-			markdown: []byte(
-				"[link1](http://example.com) ... [linkN](http://exampleN.com)",
-			),
-			want: []string{"http://example.com", "http://exampleN.com"},
-		},
-		{
-			name: "Special Characters in URLs",
-			markdown: []byte(
-				"[link](http://example.com?query=value&param=value)",
-			),
-			want: []string{"http://example.com?query=value&param=value"},
-		},
-
-		{
-			name:     "Unicode and Encoding",
-			markdown: []byte("[链接](http://例子.公司)"),
-			want:     []string{"http://例子.公司"},
-		},
-		{
-			name:     "Nil",
-			markdown: nil,
-			want:     []string{},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, _ := findLinks(tt.markdown)
-
-			// Treat nil slices as equivalent to empty slices
-			if len(got) == 0 && len(tt.want) == 0 {
-				return
-			}
-
-			assert.Equal(
-				t,
-				tt.want,
-				got,
-				"findLinks() did not return expected result",
-			)
-		})
-	}
-}
-
 // TestCheckLink_Success tests the checkUrl function with a successful HTTP request
 func TestCheckLink_Success(t *testing.T) {
 	t.Parallel()
@@ -298,7 +208,7 @@ func TestPrintFilepath(t *testing.T) {
 	w := tabwriter.NewWriter(&buf, 0, 0, 1, ' ', 0)
 	defer w.Flush()
 
-	printFilepath(w, "testfile.md", false)
+	printFilepath(w, "testfile.md")
 	assert.Equal(
 		t,
 		expectedOutput,
@@ -310,7 +220,13 @@ func TestPrintFilepath(t *testing.T) {
 // Test for printLinkRecordTab function
 func TestPrintLinkRecordTab(t *testing.T) {
 	t.Parallel()
-	linkRecord := linkRecord{"http://example.com", 200, true, "OK", 1}
+	linkRecord := linkRecord{
+		Location:   "http://example.com",
+		StatusCode: 200,
+		OK:         true,
+		Message:    "OK",
+		Attempt:    1,
+	}
 	expectedOutput := "- Location   : http://example.com\n" +
 		"  Status Code: 200\n" +
 		"  OK         : true\n" +
@@ -330,75 +246,6 @@ func TestPrintLinkRecordTab(t *testing.T) {
 	)
 }
 
-// Test for printLinkRecordJSON function
-func TestPrintLinkRecordJSON(t *testing.T) {
-	t.Parallel()
-	linkRecord := linkRecord{"http://example.com", 200, true, "OK", 1}
-	expectedOutput := "{\n" +
-		"  \"location\": \"http://example.com\",\n" +
-		"  \"statusCode\": 200,\n" +
-		"  \"ok\": true,\n" +
-		"  \"message\": \"OK\",\n" +
-		"  \"attempt\": 1\n" +
-		"}\n"
-
-	var buf bytes.Buffer
-	w := tabwriter.NewWriter(&buf, 0, 0, 1, ' ', 0)
-	defer w.Flush()
-
-	_ = printLinkRecordJSON(w, linkRecord)
-	assert.Equal(
-		t,
-		expectedOutput,
-		buf.String(),
-		"printLinkRecordJSON() did not return expected result",
-	)
-}
-
-// Test for printLinkRecord function
-func TestPrintLinkRecord(t *testing.T) {
-	t.Parallel()
-	linkRecord := linkRecord{"http://example.com", 200, true, "OK", 1}
-	expectedOutput := "- Location   : http://example.com\n" +
-		"  Status Code: 200\n" +
-		"  OK         : true\n" +
-		"  Message    : OK\n" +
-		"  Attempt    : 1\n\n"
-
-	var buf bytes.Buffer
-	w := tabwriter.NewWriter(&buf, 0, 0, 1, ' ', 0)
-	defer w.Flush()
-
-	_ = printLinkRecord(w, linkRecord, false)
-	assert.Equal(
-		t,
-		expectedOutput,
-		buf.String(),
-		"printLinkRecord() did not return expected result",
-	)
-
-	// Test with JSON output
-	expectedOutput = "{\n" +
-		"  \"location\": \"http://example.com\",\n" +
-		"  \"statusCode\": 200,\n" +
-		"  \"ok\": true,\n" +
-		"  \"message\": \"OK\",\n" +
-		"  \"attempt\": 1\n" +
-		"}\n"
-
-	var buf2 bytes.Buffer
-	w2 := tabwriter.NewWriter(&buf2, 0, 0, 1, ' ', 0)
-	defer w2.Flush()
-
-	_ = printLinkRecord(w2, linkRecord, true)
-	assert.Equal(
-		t,
-		expectedOutput,
-		buf2.String(),
-		"printLinkRecord() did not return expected result",
-	)
-}
-
 func TestCheckLinks(t *testing.T) {
 	t.Parallel()
 	// Create a test tabwriter.Writer
@@ -419,14 +266,20 @@ func TestCheckLinks(t *testing.T) {
 
 	// Set the timeout and error flag for testing
 	timeout := time.Second
-	errOK := false
-	asJSON := false
+	failOn := severityError
 	maxRetries := 1
 	startBackoff := 1 * time.Second
 	maxBackoff := 1 * time.Second
 
 	// Call the checkLinks function
-	_ = checkLinks(w, urls, timeout, maxRetries, startBackoff, maxBackoff, errOK, asJSON)
+	c := newChecker(options{
+		Timeout:      timeout,
+		MaxRetries:   maxRetries,
+		StartBackoff: startBackoff,
+		MaxBackoff:   maxBackoff,
+	})
+	defer c.close()
+	_ = checkLinks(w, linksOf(urls...), c, failOn)
 
 	output := buf.String()
 
@@ -434,23 +287,31 @@ func TestCheckLinks(t *testing.T) {
 	expectedOutput1 := "- Location   : " + ts.URL + "/ok\n" +
 		"  Status Code: 200\n" +
 		"  OK         : true\n" +
+		"  Severity   : ok\n" +
 		"  Message    : OK\n" +
-		"  Attempt    : 1\n\n" +
+		"  Attempt    : 1\n" +
+		"  Position   : test.md:1:1\n\n" +
 		"- Location   : " + ts.URL + "/invalid-url\n" +
 		"  Status Code: 200\n" +
 		"  OK         : true\n" +
+		"  Severity   : ok\n" +
 		"  Message    : OK\n" +
-		"  Attempt    : 1\n\n"
+		"  Attempt    : 1\n" +
+		"  Position   : test.md:2:1\n\n"
 	expectedOutput2 := "- Location   : " + ts.URL + "/invalid-url\n" +
 		"  Status Code: 200\n" +
 		"  OK         : true\n" +
+		"  Severity   : ok\n" +
 		"  Message    : OK\n" +
-		"  Attempt    : 1\n\n" +
+		"  Attempt    : 1\n" +
+		"  Position   : test.md:2:1\n\n" +
 		"- Location   : " + ts.URL + "/ok\n" +
 		"  Status Code: 200\n" +
 		"  OK         : true\n" +
+		"  Severity   : ok\n" +
 		"  Message    : OK\n" +
-		"  Attempt    : 1\n\n"
+		"  Attempt    : 1\n" +
+		"  Position   : test.md:1:1\n\n"
 
 	assert.Contains(
 		t,
@@ -465,7 +326,7 @@ func TestCheckLinks_RaisesError(t *testing.T) {
 	tests := []struct {
 		description     string
 		serverResponses map[string]int // URL path to response code
-		ignoreErrors    bool
+		failOn          severity
 		expectError     bool
 	}{
 		{
@@ -474,8 +335,8 @@ func TestCheckLinks_RaisesError(t *testing.T) {
 				"/good": http.StatusOK,
 				"/bad":  http.StatusInternalServerError,
 			},
-			ignoreErrors: false,
-			expectError:  true,
+			failOn:      severityError,
+			expectError: true,
 		},
 		{
 			description: "should not error with all good links",
@@ -483,8 +344,8 @@ func TestCheckLinks_RaisesError(t *testing.T) {
 				"/good1": http.StatusOK,
 				"/good2": http.StatusOK,
 			},
-			ignoreErrors: false,
-			expectError:  false,
+			failOn:      severityError,
+			expectError: false,
 		},
 		{
 			description: "should not error with bad links when ignoring errors",
@@ -492,8 +353,8 @@ func TestCheckLinks_RaisesError(t *testing.T) {
 				"/good": http.StatusOK,
 				"/bad":  http.StatusInternalServerError,
 			},
-			ignoreErrors: true,
-			expectError:  false,
+			failOn:      failNever,
+			expectError: false,
 		},
 	}
 
@@ -517,18 +378,16 @@ func TestCheckLinks_RaisesError(t *testing.T) {
 	}
 
 	runCheckLinks := func(
-		w *tabwriter.Writer, urls []string, timeout time.Duration, ignoreErrors bool,
+		w *tabwriter.Writer, urls []string, timeout time.Duration, failOn severity,
 	) bool {
-		err := checkLinks(
-			w,
-			urls,
-			timeout,
-			1,
-			1*time.Second,
-			1*time.Second,
-			ignoreErrors,
-			false,
-		)
+		c := newChecker(options{
+			Timeout:      timeout,
+			MaxRetries:   1,
+			StartBackoff: 1 * time.Second,
+			MaxBackoff:   1 * time.Second,
+		})
+		defer c.close()
+		err := checkLinks(w, linksOf(urls...), c, failOn)
 		return err != nil
 	}
 
@@ -546,7 +405,7 @@ func TestCheckLinks_RaisesError(t *testing.T) {
 				w,
 				urls,
 				5*time.Second,
-				test.ignoreErrors,
+				test.failOn,
 			)
 
 			assert.Equal(t, test.expectError, errOccurred)
@@ -575,32 +434,58 @@ func TestCheckLinks_Retry(t *testing.T) {
 
 	// Set the timeout and error flag for testing
 	timeout := time.Second
-	errOK := false
-	asJSON := true
+	failOn := severityError
 	maxRetries := 2
 	startBackoff := 10 * time.Millisecond
 	maxBackoff := 20 * time.Millisecond
 
 	// Call the checkLinks function
-	_ = checkLinks(w, urls, timeout, maxRetries, startBackoff, maxBackoff, errOK, asJSON)
-
-	output := buf.String()
+	c := newChecker(options{
+		Timeout:      timeout,
+		MaxRetries:   maxRetries,
+		StartBackoff: startBackoff,
+		MaxBackoff:   maxBackoff,
+		Report:       newNDJSONReport("", failOn, false),
+	})
+	defer c.close()
+	_ = checkLinks(w, linksOf(urls...), c, failOn)
+	w.Flush()
 
 	// Verify the output
-	expectedOutput := "{\n" +
-		"  \"location\": \"" + ts.URL + "/error1\",\n" +
-		"  \"statusCode\": 500,\n" +
-		"  \"ok\": false,\n" +
-		"  \"message\": \"Internal Server Error\",\n" +
-		"  \"attempt\": 2\n" +
-		"}\n"
+	records := make(map[string]linkRecord)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var v struct {
+			Type string `json:"type"`
+			linkRecord
+		}
+		require.NoError(t, json.Unmarshal([]byte(line), &v))
+		if v.Type == "link" {
+			records[v.Location] = v.linkRecord
+		}
+	}
+	require.Len(t, records, 2)
+
+	record := records[ts.URL+"/error1"]
+	assert.Equal(t, targetHTTP, record.Target)
+	assert.Equal(t, "GET", record.Method)
+	assert.Equal(t, 500, record.StatusCode)
+	assert.False(t, record.OK)
+	assert.Equal(t, severityError, record.Severity)
+	assert.Equal(t, "Internal Server Error", record.Message)
+	assert.Equal(t, 2, record.Attempt)
+	// The first attempt falls back from HEAD to GET
+	require.Len(t, record.Attempts, 3)
+	for i, method := range []string{"HEAD", "GET", "GET"} {
+		assert.Equal(t, max(i, 1), record.Attempts[i].Attempt)
+		assert.Equal(t, method, record.Attempts[i].Method)
+		assert.Equal(t, 500, record.Attempts[i].StatusCode)
+	}
+	assert.Equal(t, []linkOccurrence{
+		{Filepath: "test.md", Line: 1, Column: 1, Text: "link", Kind: kindInline},
+	}, record.Occurrences)
 
-	assert.Contains(
-		t,
-		output,
-		expectedOutput,
-		"checkLinks() did not return expected result",
-	)
+	// Timings vary, so only whether the retries backed off is compared
+	assert.Equal(t, 2, strings.Count(buf.String(), "\"backoffMs\""))
 }
 
 // Test CLI e2e
@@ -770,10 +655,14 @@ func TestCLI_PrintJSON(t *testing.T) {
 	CLI(w, "0.1.0-test", mockExit)
 
 	// Verify that the CLI prints the usage
+	w.Flush()
 	output := out.String()
-	assert.Contains(t, output, "{\n  \"location\": \"https://not.either\"")
+	assert.True(t, json.Valid(out.Bytes()), "output should be a single JSON document")
+	assert.Contains(t, output, `"location": "https://not.either"`)
 	assert.Contains(t, output, `"statusCode": 0`)
 	assert.Contains(t, output, `"ok": false`)
+	assert.Contains(t, output, `"line": 3`)
+	assert.Contains(t, output, `"kind": "reference"`)
 }
 
 func TestCLI_MarkdownDoesNotExist(t *testing.T) {
@@ -852,7 +741,7 @@ func TestCLI_ErrorOkExitsWithCodeZero(t *testing.T) {
 
 	// Simulate executing -f and -e flags
 	args := os.Args[0:1] // Keep the program name only
-	args = append(args, "-f", filePath)
+	args = append(args, "-f", filePath, "-e")
 	os.Args = args
 
 	CLI(w, "0.1.0-test", os.Exit)
@@ -864,8 +753,15 @@ func TestCLI_ErrorOkExitsWithCodeZero(t *testing.T) {
 }
 
 func TestCLI_Retry(t *testing.T) {
-	// Create a sample markdown file
-	filePath := MakeMockMarkdownFile()
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}),
+	)
+	defer ts.Close()
+
+	filePath := filepath.Join(t.TempDir(), "retry.md")
+	require.NoError(t, os.WriteFile(filePath, []byte("[flaky]("+ts.URL+")"), 0o600))
 
 	// Capture the output by using a bytes.Buffer
 	var out bytes.Buffer
@@ -889,12 +785,54 @@ func TestCLI_Retry(t *testing.T) {
 	)
 	os.Args = args
 
-	CLI(w, "0.1.0-test", os.Exit)
+	CLI(w, "0.1.0-test", func(int) {})
 
-	// Verify that the CLI exits with code 0. This means the program did not
-	// encounter any errors
+	// The 502 is retried until the retries run out
 	fmt.Println(out.String())
-	assert.Contains(t, out.String(), "Attempt    : 2\n\n")
+	assert.Contains(t, out.String(), "Attempt    : 2\n  Position   : ")
+}
+
+func TestCLI_MultipleFiles(t *testing.T) {
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/bad" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	defer ts.Close()
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "docs"), 0o755))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(root, "README.md"),
+		[]byte("[good]("+ts.URL+"/good)"),
+		0o600,
+	))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(root, "docs", "guide.md"),
+		[]byte("[bad]("+ts.URL+"/bad)"),
+		0o600,
+	))
+
+	exitCode := 0
+	mockExit := func(code int) { exitCode = code }
+
+	var out bytes.Buffer
+	w := tabwriter.NewWriter(&out, 0, 4, 4, ' ', 0)
+
+	os.Args = []string{os.Args[0], "-f", root}
+	CLI(w, "0.1.0-test", mockExit)
+	w.Flush()
+
+	output := out.String()
+	assert.Contains(t, output, "Filepath: "+filepath.Join(root, "README.md"))
+	assert.Contains(t, output, "Filepath: "+filepath.Join(root, "docs", "guide.md"))
+	assert.Contains(t, output, "Location   : "+ts.URL+"/good")
+	assert.Contains(t, output, "Location   : "+ts.URL+"/bad")
+	assert.Equal(t, 1, exitCode)
 }
 
 // Benchmark for checkUrls
@@ -927,15 +865,97 @@ func BenchmarkCheckUrls(b *testing.B) {
 	w := tabwriter.NewWriter(&buf, 0, 0, 1, ' ', 0)
 
 	for i := 0; i < b.N; i++ {
-		_ = checkLinks(
-			w,
-			testUrls,
-			1*time.Second,
-			1,
-			1*time.Second,
-			1*time.Second,
-			true,
-			false,
-		)
+		c := newChecker(options{
+			Timeout:      1 * time.Second,
+			MaxRetries:   1,
+			StartBackoff: 1 * time.Second,
+			MaxBackoff:   1 * time.Second,
+			Concurrency:  len(testUrls),
+		})
+		_ = checkLinks(w, linksOf(testUrls...), c, failNever)
+		c.close()
+	}
+}
+
+// Benchmark the throughput of checkLinks for different pool sizes and
+// per-host caps against a server with a fixed latency
+func BenchmarkCheckUrls_Concurrency(b *testing.B) {
+	const latency = 5 * time.Millisecond
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(latency)
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	defer ts.Close()
+
+	testUrls := make([]string, 64)
+	for i := range testUrls {
+		testUrls[i] = fmt.Sprintf("%s/page/%d", ts.URL, i)
+	}
+
+	for _, bc := range []struct {
+		concurrency     int
+		hostConcurrency int
+	}{
+		{1, 0},
+		{4, 0},
+		{16, 0},
+		{64, 0},
+		{16, 4},
+		{64, 8},
+	} {
+		name := fmt.Sprintf("concurrency=%d/host=%d", bc.concurrency, bc.hostConcurrency)
+		b.Run(name, func(b *testing.B) {
+			w := tabwriter.NewWriter(io.Discard, 0, 0, 1, ' ', 0)
+			for i := 0; i < b.N; i++ {
+				c := newChecker(options{
+					Timeout:         1 * time.Second,
+					MaxRetries:      1,
+					StartBackoff:    1 * time.Second,
+					MaxBackoff:      1 * time.Second,
+					Concurrency:     bc.concurrency,
+					HostConcurrency: bc.hostConcurrency,
+				})
+				_ = checkLinks(w, linksOf(testUrls...), c, failNever)
+				c.close()
+			}
+			b.ReportMetric(
+				float64(len(testUrls)*b.N)/b.Elapsed().Seconds(),
+				"urls/s",
+			)
+		})
+	}
+}
+
+func TestCLI_PrintsInvalidOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.md")
+	require.NoError(t, os.WriteFile(path, []byte("# Empty\n"), 0o600))
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--fail-on", "bogus"}, `unknown severity "bogus" for fail-on`},
+		{[]string{"--format", "xml"}, `unknown output format "xml"`},
+		{[]string{"--method", "post"}, `unknown request method "post"`},
+		{[]string{"--backoff", "slow"}, `unknown backoff strategy "slow"`},
+		{[]string{"--slug", "wiki"}, `unknown slug algorithm "wiki"`},
+		{[]string{"--concurrency", "0"}, "concurrency should be at least 1"},
+		{[]string{"--host-concurrency", "-1"}, "host-concurrency should not be negative"},
+		{[]string{"--max-redirects", "-1"}, "max-redirects should not be negative"},
+		{[]string{"--start-backoff", "0s"}, "start-backoff should be at least 1ms"},
+		{[]string{"--retry-on", "bogus"}, "retry-on"},
+	}
+
+	for _, tt := range tests {
+		code := 0
+		var out bytes.Buffer
+		os.Args = append([]string{os.Args[0], "-f", path}, tt.args...)
+		CLI(&out, "0.1.0-test", func(c int) { code = c })
+
+		assert.Equal(t, 2, code, tt.args)
+		assert.Contains(t, out.String(), tt.want, tt.args)
+		assert.Equal(t, 1, strings.Count(out.String(), "\n"), tt.args)
 	}
 }
@@ -0,0 +1,371 @@
+package src
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// configNames are the files looked up in the working directory and its
+// parents when --config isn't provided.
+var configNames = []string{".link-patrol.yaml", ".link-patrol.yml", ".link-patrol.toml"}
+
+// pathKeys are the config keys whose relative paths resolve against the
+// directory of the config file instead of the working directory.
+var pathKeys = map[string]bool{"filepath": true, "root": true}
+
+// hostConfig holds the settings that override the global ones for a host.
+// Zero values mean the global setting applies.
+type hostConfig struct {
+	Timeout     time.Duration
+	MaxRetries  int
+	Concurrency int
+
+	// Accept replaces the global --accept rules for the host.
+	Accept *acceptPolicy
+
+	// Headers are sent with every request to the host, on top of the
+	// --header values. They include the Authorization header built from
+	// the bearer-token-env and basic-auth-env keys.
+	Headers http.Header
+}
+
+// fileConfig is the content of a config file. Flags maps flag names to the
+// values set in the file and Hosts maps host names or glob patterns like
+// *.example.com to their settings.
+type fileConfig struct {
+	Path  string
+	Flags map[string]any
+	Hosts map[string]hostConfig
+}
+
+// findConfig walks up from dir and returns the path of the first config
+// file it finds, or an empty string if there's none.
+func findConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to find config: %w", err)
+	}
+
+	for {
+		for _, name := range configNames {
+			p := filepath.Join(dir, name)
+			if info, err := os.Stat(p); err == nil && !info.IsDir() {
+				return p, nil
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// loadConfig reads the YAML or TOML config file at p.
+func loadConfig(p string) (*fileConfig, error) {
+	content, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	raw := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".yaml", ".yml":
+		err = yaml.NewDecoder(bytes.NewReader(content)).Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case ".toml":
+		_, err = toml.Decode(string(content), &raw)
+	default:
+		err = errors.New("config must be a .yaml, .yml or .toml file")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+
+	cfg, err := parseConfig(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	cfg.Path = p
+	return cfg, nil
+}
+
+// parseConfig splits the decoded config into flag values and host settings.
+func parseConfig(raw map[string]any) (*fileConfig, error) {
+	cfg := &fileConfig{
+		Flags: make(map[string]any),
+		Hosts: make(map[string]hostConfig),
+	}
+
+	for key, value := range raw {
+		if key != "hosts" {
+			cfg.Flags[key] = value
+			continue
+		}
+
+		hosts, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("key %q: expected a table of hosts", key)
+		}
+		for _, host := range sortedKeys(hosts) {
+			hc, err := parseHostConfig("hosts."+host, hosts[host])
+			if err != nil {
+				return nil, err
+			}
+			cfg.Hosts[strings.ToLower(host)] = hc
+		}
+	}
+	return cfg, nil
+}
+
+// parseHostConfig validates the settings of a single host.
+func parseHostConfig(key string, value any) (hostConfig, error) {
+	settings, ok := value.(map[string]any)
+	if !ok {
+		return hostConfig{}, fmt.Errorf("key %q: expected a table of settings", key)
+	}
+
+	var hc hostConfig
+	for _, name := range sortedKeys(settings) {
+		value := settings[name]
+		key := key + "." + name
+
+		switch name {
+		case "timeout":
+			d, err := time.ParseDuration(fmt.Sprint(value))
+			if err != nil || d <= 0 {
+				return hostConfig{}, fmt.Errorf(
+					"key %q: expected a positive duration like 10s, got %v", key, value,
+				)
+			}
+			hc.Timeout = d
+		case "max-retries", "concurrency":
+			n, ok := asInt(value)
+			if !ok || n < 1 {
+				return hostConfig{}, fmt.Errorf(
+					"key %q: expected an integer of at least 1, got %v", key, value,
+				)
+			}
+			if name == "max-retries" {
+				hc.MaxRetries = n
+			} else {
+				hc.Concurrency = n
+			}
+		case "accept":
+			values, err := flagValues(value, true)
+			if err != nil {
+				return hostConfig{}, fmt.Errorf("key %q: %w", key, err)
+			}
+			hc.Accept = &acceptPolicy{}
+			for _, v := range values {
+				rule, err := parseAcceptRule(v)
+				if err != nil {
+					return hostConfig{}, fmt.Errorf("key %q: %w", key, err)
+				}
+				hc.Accept.rules = append(hc.Accept.rules, rule)
+			}
+		case "headers":
+			headers, ok := value.(map[string]any)
+			if !ok {
+				return hostConfig{}, fmt.Errorf(
+					"key %q: expected a table of header names and values", key,
+				)
+			}
+			if hc.Headers == nil {
+				hc.Headers = make(http.Header)
+			}
+			for _, header := range sortedKeys(headers) {
+				addHeader(hc.Headers, header, fmt.Sprint(headers[header]))
+			}
+		case "bearer-token-env", "basic-auth-env":
+			// Credentials only come from the environment so that they never
+			// live in the config file. Unset variables send no credentials.
+			env := fmt.Sprint(value)
+			credentials := os.Getenv(env)
+			if credentials == "" {
+				continue
+			}
+			auth := "Bearer " + credentials
+			if name == "basic-auth-env" {
+				if auth, ok = basicAuth(credentials); !ok {
+					return hostConfig{}, fmt.Errorf(
+						"key %q: expected %s to hold user:password", key, env,
+					)
+				}
+			}
+			if hc.Headers == nil {
+				hc.Headers = make(http.Header)
+			}
+			hc.Headers.Set("Authorization", auth)
+		default:
+			return hostConfig{}, fmt.Errorf("unknown key %q", key)
+		}
+	}
+	return hc, nil
+}
+
+// asInt converts the integers decoded from YAML or TOML to an int.
+func asInt(value any) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case uint64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+// sortedKeys returns the keys of m in order, so that errors are stable.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// flagValues turns a config value into the strings the flag parses. Only
+// slice flags accept lists.
+func flagValues(value any, slice bool) ([]string, error) {
+	switch v := value.(type) {
+	case []any:
+		if !slice {
+			return nil, errors.New("expected a single value, got a list")
+		}
+		values := make([]string, 0, len(v))
+		for _, elem := range v {
+			elemValues, err := flagValues(elem, false)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, elemValues...)
+		}
+		return values, nil
+	case map[string]any:
+		return nil, errors.New("expected a value, got a table")
+	case nil:
+		return nil, errors.New("expected a value")
+	}
+	return []string{fmt.Sprint(value)}, nil
+}
+
+// contextConfig loads the config file passed with --config or the first one
+// found from the working directory up. It returns nil if there's none.
+func contextConfig(c *cli.Context) (*fileConfig, error) {
+	p := c.String("config")
+	if p == "" {
+		var err error
+		if p, err = findConfig("."); err != nil || p == "" {
+			return nil, err
+		}
+	}
+	return loadConfig(p)
+}
+
+// applyConfig sets every flag of the config that wasn't set on the command
+// line or through an environment variable, so flags win over env vars, env
+// vars over the config file and the config file over defaults.
+func applyConfig(c *cli.Context, cfg *fileConfig) error {
+	flags := make(map[string]cli.Flag)
+	for _, f := range c.App.Flags {
+		flags[f.Names()[0]] = f
+	}
+
+	wd, _ := os.Getwd()
+	dir := filepath.Dir(cfg.Path)
+
+	for _, key := range sortedKeys(cfg.Flags) {
+		f, ok := flags[key]
+		if !ok || key == "config" {
+			return fmt.Errorf("%s: unknown key %q", cfg.Path, key)
+		}
+		if c.IsSet(key) {
+			continue
+		}
+
+		_, slice := f.(*cli.StringSliceFlag)
+		values, err := flagValues(cfg.Flags[key], slice)
+		if err != nil {
+			return fmt.Errorf("%s: key %q: %w", cfg.Path, key, err)
+		}
+
+		for _, value := range values {
+			if pathKeys[key] {
+				value = resolveConfigPath(wd, dir, value)
+			}
+			if err := c.Set(key, value); err != nil {
+				return fmt.Errorf("%s: key %q: invalid value %q, %s",
+					cfg.Path, key, value, expected(f, err))
+			}
+		}
+	}
+	return nil
+}
+
+// expected describes the values a flag accepts.
+func expected(f cli.Flag, err error) string {
+	switch f.(type) {
+	case *cli.DurationFlag:
+		return "expected a duration like 10s"
+	case *cli.IntFlag:
+		return "expected an integer"
+	case *cli.BoolFlag:
+		return "expected true or false"
+	}
+	return err.Error()
+}
+
+// resolveConfigPath resolves a relative path from the config file against
+// the directory of the file. The result is relative to the working
+// directory when possible to keep the output short.
+func resolveConfigPath(wd, dir, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	joined := filepath.Join(dir, p)
+	if filepath.IsAbs(joined) && wd != "" {
+		if rel, err := filepath.Rel(wd, joined); err == nil {
+			return rel
+		}
+	}
+	return joined
+}
+
+// envVars returns the environment variable a flag reads its value from,
+// like LINK_PATROL_MAX_RETRIES for max-retries.
+func envVars(name string) []string {
+	return []string{"LINK_PATROL_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))}
+}
+
+// hostSettings returns the settings for host. An exact match wins over glob
+// patterns, which are tried in lexical order.
+func hostSettings(hosts map[string]hostConfig, host string) hostConfig {
+	if hc, ok := hosts[host]; ok {
+		return hc
+	}
+	for _, pattern := range sortedKeys(hosts) {
+		if ok, _ := path.Match(pattern, host); ok {
+			return hosts[pattern]
+		}
+	}
+	return hostConfig{}
+}
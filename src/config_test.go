@@ -0,0 +1,319 @@
+package src
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+// writeConfig writes a config file named name to dir and returns its path
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+	return p
+}
+
+// runConfig runs an app with a few of the CLI flags, applies cfg and
+// returns the resulting context values
+func runConfig(t *testing.T, cfg *fileConfig, args ...string) (map[string]any, error) {
+	t.Helper()
+	var values map[string]any
+	app := &cli.App{
+		Writer:    io.Discard,
+		ErrWriter: io.Discard,
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{Name: "filepath", EnvVars: envVars("filepath")},
+			&cli.DurationFlag{
+				Name: "timeout", Value: 5 * time.Second, EnvVars: envVars("timeout"),
+			},
+			&cli.IntFlag{Name: "max-retries", Value: 1, EnvVars: envVars("max-retries")},
+			&cli.BoolFlag{Name: "json", EnvVars: envVars("json")},
+			&cli.StringFlag{Name: "config"},
+		},
+		Action: func(c *cli.Context) error {
+			if err := applyConfig(c, cfg); err != nil {
+				return err
+			}
+			values = map[string]any{
+				"filepath":    c.StringSlice("filepath"),
+				"timeout":     c.Duration("timeout"),
+				"max-retries": c.Int("max-retries"),
+				"json":        c.Bool("json"),
+			}
+			return nil
+		},
+	}
+	err := app.Run(append([]string{"link-patrol"}, args...))
+	return values, err
+}
+
+func TestFindConfig(t *testing.T) {
+	t.Parallel()
+	root := makeTree(t, "docs/sub/a.md")
+	p := writeConfig(t, root, ".link-patrol.toml", "")
+
+	got, err := findConfig(filepath.Join(root, "docs", "sub"))
+	require.NoError(t, err)
+	assert.Equal(t, p, got)
+
+	// YAML wins over TOML in the same directory
+	p = writeConfig(t, root, ".link-patrol.yaml", "")
+	got, err = findConfig(root)
+	require.NoError(t, err)
+	assert.Equal(t, p, got)
+
+	// The nearest config wins
+	p = writeConfig(t, filepath.Join(root, "docs"), ".link-patrol.yml", "")
+	got, err = findConfig(filepath.Join(root, "docs", "sub"))
+	require.NoError(t, err)
+	assert.Equal(t, p, got)
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	yamlPath := writeConfig(t, dir, "a.yaml", `
+timeout: 10s
+filepath: [docs, README.md]
+json: true
+hosts:
+  GitHub.com:
+    timeout: 30s
+    max-retries: 3
+  "*.example.com":
+    concurrency: 1
+`)
+	tomlPath := writeConfig(t, dir, "a.toml", `
+timeout = "10s"
+filepath = ["docs", "README.md"]
+json = true
+
+[hosts."GitHub.com"]
+timeout = "30s"
+max-retries = 3
+
+[hosts."*.example.com"]
+concurrency = 1
+`)
+
+	for _, p := range []string{yamlPath, tomlPath} {
+		cfg, err := loadConfig(p)
+		require.NoError(t, err, p)
+
+		assert.Equal(t, p, cfg.Path)
+		assert.Equal(t, "10s", cfg.Flags["timeout"], p)
+		assert.Equal(t, true, cfg.Flags["json"], p)
+		assert.Equal(t, []any{"docs", "README.md"}, cfg.Flags["filepath"], p)
+		assert.Equal(t, map[string]hostConfig{
+			"github.com":    {Timeout: 30 * time.Second, MaxRetries: 3},
+			"*.example.com": {Concurrency: 1},
+		}, cfg.Hosts, p)
+	}
+
+	// An empty file is a valid config
+	cfg, err := loadConfig(writeConfig(t, dir, "empty.yaml", ""))
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Flags)
+}
+
+func TestLoadConfig_Errors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"a.json", `{}`, "config must be a .yaml, .yml or .toml file"},
+		{"syntax.yaml", "filepath: [docs\n", "yaml: line 1"},
+		{"syntax.toml", "timeout = \n", "toml: line 1"},
+		{"hosts.yaml", "hosts: [a.com]\n", `key "hosts": expected a table of hosts`},
+		{
+			"host.yaml", "hosts:\n  a.com: 1\n",
+			`key "hosts.a.com": expected a table of settings`,
+		},
+		{
+			"unknown.yaml", "hosts:\n  a.com:\n    retries: 2\n",
+			`unknown key "hosts.a.com.retries"`,
+		},
+		{
+			"timeout.toml", "[hosts.\"a.com\"]\ntimeout = \"soon\"\n",
+			`key "hosts.a.com.timeout": expected a positive duration like 10s, got soon`,
+		},
+		{
+			"retries.yaml", "hosts:\n  a.com:\n    max-retries: 0\n",
+			`key "hosts.a.com.max-retries": expected an integer of at least 1, got 0`,
+		},
+		{
+			"accept.yaml", "hosts:\n  a.com:\n    accept: [403:maybe]\n",
+			`key "hosts.a.com.accept": invalid level "maybe" in "403:maybe"`,
+		},
+		{
+			"headers.yaml", "hosts:\n  a.com:\n    headers: [X-Token]\n",
+			`key "hosts.a.com.headers": expected a table of header names and values`,
+		},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := writeConfig(t, dir, tt.name, tt.content)
+			_, err := loadConfig(p)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), p+": ")
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestLoadConfig_Credentials(t *testing.T) {
+	t.Setenv("LP_TEST_TOKEN", "s3cret")
+	t.Setenv("LP_TEST_BASIC", "user:pass")
+	t.Setenv("LP_TEST_BAD", "pass")
+
+	p := writeConfig(t, t.TempDir(), "c.yaml", `
+hosts:
+  github.com:
+    bearer-token-env: LP_TEST_TOKEN
+    headers:
+      Accept: text/html
+      X-Org: ${LP_TEST_TOKEN}-org
+  wiki.internal:
+    basic-auth-env: LP_TEST_BASIC
+  forks.example.com:
+    bearer-token-env: LP_TEST_UNSET
+  bad.example.com:
+    basic-auth-env: LP_TEST_BAD
+`)
+
+	_, err := loadConfig(p)
+	require.Error(t, err)
+	assert.Contains(
+		t,
+		err.Error(),
+		`key "hosts.bad.example.com.basic-auth-env": `+
+			"expected LP_TEST_BAD to hold user:password",
+	)
+
+	t.Setenv("LP_TEST_BAD", "user:pass")
+	cfg, err := loadConfig(p)
+	require.NoError(t, err)
+	assert.Equal(t, http.Header{
+		"Authorization": {"Bearer s3cret"},
+		"Accept":        {"text/html"},
+		"X-Org":         {"s3cret-org"},
+	}, cfg.Hosts["github.com"].Headers)
+	assert.Equal(t, http.Header{"Authorization": {"Basic dXNlcjpwYXNz"}},
+		cfg.Hosts["wiki.internal"].Headers)
+
+	// Without the variable, no credentials are sent
+	assert.Nil(t, cfg.Hosts["forks.example.com"].Headers)
+}
+
+func TestApplyConfig_Precedence(t *testing.T) {
+	cfg := &fileConfig{
+		Path: filepath.Join(t.TempDir(), ".link-patrol.yaml"),
+		Flags: map[string]any{
+			"timeout":     "10s",
+			"max-retries": 3,
+			"json":        true,
+		},
+	}
+
+	// Config over defaults
+	values, err := runConfig(t, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, values["timeout"])
+	assert.Equal(t, 3, values["max-retries"])
+	assert.Equal(t, true, values["json"])
+
+	// Env vars over config
+	t.Setenv("LINK_PATROL_TIMEOUT", "20s")
+	t.Setenv("LINK_PATROL_MAX_RETRIES", "4")
+	values, err = runConfig(t, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 20*time.Second, values["timeout"])
+	assert.Equal(t, 4, values["max-retries"])
+
+	// Flags over env vars
+	values, err = runConfig(t, cfg, "--timeout", "30s")
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, values["timeout"])
+	assert.Equal(t, 4, values["max-retries"])
+	assert.Equal(t, true, values["json"])
+}
+
+func TestApplyConfig_Paths(t *testing.T) {
+	t.Parallel()
+	cfg := &fileConfig{
+		Path:  filepath.Join("configs", ".link-patrol.yaml"),
+		Flags: map[string]any{"filepath": []any{"docs", "/abs/README.md"}},
+	}
+
+	values, err := runConfig(t, cfg)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{filepath.Join("configs", "docs"), "/abs/README.md"},
+		values["filepath"],
+	)
+}
+
+func TestApplyConfig_Errors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		key   string
+		value any
+		want  string
+	}{
+		{"tmeout", "1s", `c.yaml: unknown key "tmeout"`},
+		{"config", "other.yaml", `c.yaml: unknown key "config"`},
+		{
+			"timeout", 10,
+			`c.yaml: key "timeout": invalid value "10", expected a duration like 10s`,
+		},
+		{
+			"max-retries", "many",
+			`c.yaml: key "max-retries": invalid value "many", expected an integer`,
+		},
+		{"json", []any{true}, `c.yaml: key "json": expected a single value, got a list`},
+		{
+			"filepath", map[string]any{"a": 1},
+			`c.yaml: key "filepath": expected a value, got a table`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			cfg := &fileConfig{Path: "c.yaml", Flags: map[string]any{tt.key: tt.value}}
+			_, err := runConfig(t, cfg)
+			require.Error(t, err)
+			assert.Equal(t, tt.want, err.Error())
+		})
+	}
+}
+
+func TestHostSettings(t *testing.T) {
+	t.Parallel()
+	hosts := map[string]hostConfig{
+		"api.example.com": {MaxRetries: 5},
+		"*.example.com":   {Concurrency: 1},
+	}
+
+	assert.Equal(t, hostConfig{MaxRetries: 5}, hostSettings(hosts, "api.example.com"))
+	assert.Equal(t, hostConfig{Concurrency: 1}, hostSettings(hosts, "www.example.com"))
+	assert.Equal(t, hostConfig{}, hostSettings(hosts, "example.com"))
+	assert.Equal(t, hostConfig{}, hostSettings(nil, "example.com"))
+}
+
+func TestEnvVars(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, []string{"LINK_PATROL_MAX_RETRIES"}, envVars("max-retries"))
+}
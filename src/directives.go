@@ -0,0 +1,181 @@
+package src
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// directiveKind is the name of an inline directive without its
+// link-patrol- prefix.
+type directiveKind string
+
+const (
+	disableNextLine directiveKind = "disable-next-line"
+	disableBlock    directiveKind = "disable"
+	enableBlock     directiveKind = "enable"
+	disableFile     directiveKind = "disable-file"
+)
+
+// directivePattern matches directives written as HTML comments like
+// <!-- link-patrol-disable-next-line -->.
+var directivePattern = regexp.MustCompile(
+	`<!--\s*link-patrol-(disable-next-line|disable-file|disable|enable)\s*-->`,
+)
+
+// directive is an inline directive found in a markdown file. Used tells
+// whether it disabled any link, or for an enable, whether it closed a block.
+type directive struct {
+	Kind     directiveKind
+	Filepath string
+	Line     int
+	Column   int
+	Used     bool
+}
+
+// String returns the directive as it's written in markdown.
+func (d directive) String() string {
+	return "link-patrol-" + string(d.Kind)
+}
+
+// before reports whether the directive comes before the link in the file.
+func (d directive) before(link linkOccurrence) bool {
+	return d.Line < link.Line || d.Line == link.Line && d.Column < link.Column
+}
+
+// skipReason is the message of the records of the links it disables.
+func (d directive) skipReason() string {
+	return fmt.Sprintf("Skipped, disabled by %s at line %d", d, d.Line)
+}
+
+// htmlDirectives returns the directives in the HTML of a block or inline
+// node. Every segment is scanned on its own so that positions stay exact.
+func htmlDirectives(
+	node ast.Node,
+	filepath string,
+	source []byte,
+	pos position,
+) []directive {
+	var segments []text.Segment
+	switch n := node.(type) {
+	case *ast.HTMLBlock:
+		for i := 0; i < n.Lines().Len(); i++ {
+			segments = append(segments, n.Lines().At(i))
+		}
+		if n.HasClosure() {
+			segments = append(segments, n.ClosureLine)
+		}
+	case *ast.RawHTML:
+		for i := 0; i < n.Segments.Len(); i++ {
+			segments = append(segments, n.Segments.At(i))
+		}
+	}
+
+	var directives []directive
+	for _, segment := range segments {
+		value := segment.Value(source)
+		for _, m := range directivePattern.FindAllSubmatchIndex(value, -1) {
+			line, column := pos.at(segment.Start + m[0])
+			directives = append(directives, directive{
+				Kind:     directiveKind(value[m[2]:m[3]]),
+				Filepath: filepath,
+				Line:     line,
+				Column:   column,
+			})
+		}
+	}
+	return directives
+}
+
+// applyDirectives sets the SkipReason of every link that a directive
+// disables and marks the directives that took effect as used. Both slices
+// must be in the order they appear in the file.
+func applyDirectives(links []linkOccurrence, directives []directive) {
+	var file *directive
+	for i := range directives {
+		if directives[i].Kind == disableFile {
+			file = &directives[i]
+			break
+		}
+	}
+
+	// Blocks open at a disable and close at the next enable.
+	var block *directive
+	step := func(d *directive) {
+		switch d.Kind {
+		case disableBlock:
+			block = d
+		case enableBlock:
+			if block != nil {
+				d.Used = true
+				block = nil
+			}
+		}
+	}
+
+	next := 0
+	for i := range links {
+		link := &links[i]
+		for ; next < len(directives) && directives[next].before(*link); next++ {
+			step(&directives[next])
+		}
+
+		// The closest directive wins: the line above, then the block and
+		// then the whole file.
+		var by *directive
+		for j := next - 1; j >= 0 && directives[j].Line >= link.Line-1; j-- {
+			d := &directives[j]
+			if d.Kind == disableNextLine && d.Line == link.Line-1 {
+				by = d
+				break
+			}
+		}
+		if by == nil {
+			by = block
+		}
+		if by == nil {
+			by = file
+		}
+		if by != nil {
+			by.Used = true
+			link.SkipReason = by.skipReason()
+		}
+	}
+
+	// Enables after the last link still close their blocks.
+	for ; next < len(directives); next++ {
+		step(&directives[next])
+	}
+}
+
+// unusedDirectives returns the directives that didn't take effect.
+func unusedDirectives(directives []directive) []directive {
+	var unused []directive
+	for _, d := range directives {
+		if !d.Used {
+			unused = append(unused, d)
+		}
+	}
+	return unused
+}
+
+// unusedMessage explains why a directive is unused.
+func unusedMessage(d directive) string {
+	if d.Kind == enableBlock {
+		return "Unused, there's no link-patrol-disable to close"
+	}
+	return "Unused, no link is disabled by it"
+}
+
+// printUnusedDirective prints a directive that didn't take effect in
+// tabular format.
+func printUnusedDirective(w io.Writer, d directive) error {
+	_, err := fmt.Fprintf(w,
+		"- Directive  : %s\n  Message    : %s\n  Position   : %s:%d:%d\n\n",
+		d, unusedMessage(d), d.Filepath, d.Line, d.Column,
+	)
+	return err
+}
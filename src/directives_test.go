@@ -0,0 +1,151 @@
+package src
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// skipReasons maps the URL of every link to its skip reason
+func skipReasons(links []linkOccurrence) map[string]string {
+	reasons := make(map[string]string)
+	for _, link := range links {
+		reasons[link.URL] = link.SkipReason
+	}
+	return reasons
+}
+
+func TestFindLinks_DisableNextLine(t *testing.T) {
+	t.Parallel()
+	markdown := []byte(`# Tutorial
+
+<!-- link-patrol-disable-next-line -->
+Open [the app](http://localhost:3000) [docs](https://a.com)
+[checked](https://b.com)
+
+Inline <!--link-patrol-disable-next-line-->
+[sample](https://example.com/sample)
+`)
+
+	links, directives, err := findLinks("a.md", markdown)
+	require.NoError(t, err)
+	require.Len(t, directives, 2)
+	const reason = "Skipped, disabled by link-patrol-disable-next-line at line "
+	assert.Equal(t, map[string]string{
+		"http://localhost:3000":      reason + "3",
+		"https://a.com":              reason + "3",
+		"https://b.com":              "",
+		"https://example.com/sample": reason + "7",
+	}, skipReasons(links))
+	assert.Equal(t, directive{disableNextLine, "a.md", 7, 8, true}, directives[1])
+	assert.Empty(t, unusedDirectives(directives))
+}
+
+func TestFindLinks_DisableBlock(t *testing.T) {
+	t.Parallel()
+	markdown := []byte(`[before](https://a.com)
+
+<!-- link-patrol-disable -->
+
+[inside](https://b.com)
+
+` + "```" + `
+[code](https://not-a-link.com)
+` + "```" + `
+
+- [item](https://c.com)
+
+<!-- link-patrol-enable -->
+
+[after](https://d.com)
+`)
+
+	links, directives, err := findLinks("a.md", markdown)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"https://a.com": "",
+		"https://b.com": "Skipped, disabled by link-patrol-disable at line 3",
+		"https://c.com": "Skipped, disabled by link-patrol-disable at line 3",
+		"https://d.com": "",
+	}, skipReasons(links))
+	assert.Empty(t, unusedDirectives(directives))
+}
+
+func TestFindLinks_DisableFile(t *testing.T) {
+	t.Parallel()
+	markdown := []byte(`[a](https://a.com)
+
+<!-- link-patrol-disable-file -->
+
+[b](b.md)
+`)
+
+	links, directives, err := findLinks("a.md", markdown)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"https://a.com": "Skipped, disabled by link-patrol-disable-file at line 3",
+		"b.md":          "Skipped, disabled by link-patrol-disable-file at line 3",
+	}, skipReasons(links))
+	assert.Empty(t, unusedDirectives(directives))
+}
+
+func TestFindLinks_UnusedDirectives(t *testing.T) {
+	t.Parallel()
+	markdown := []byte(`<!-- link-patrol-disable-next-line -->
+No links here.
+
+<!-- link-patrol-enable -->
+
+<!-- link-patrol-disable -->
+<!-- link-patrol-enable -->
+
+<!-- link-patrol-disable-next-line -->
+
+[far](https://a.com)
+`)
+
+	links, directives, err := findLinks("a.md", markdown)
+	require.NoError(t, err)
+	assert.Equal(t, "", links[0].SkipReason)
+	assert.Equal(t, []directive{
+		{disableNextLine, "a.md", 1, 1, false},
+		{enableBlock, "a.md", 4, 1, false},
+		{disableBlock, "a.md", 6, 1, false},
+		{disableNextLine, "a.md", 9, 1, false},
+	}, unusedDirectives(directives))
+}
+
+func TestPrintUnusedDirective(t *testing.T) {
+	t.Parallel()
+	d := directive{Kind: enableBlock, Filepath: "a.md", Line: 4, Column: 1}
+
+	var buf bytes.Buffer
+	require.NoError(t, printUnusedDirective(&buf, d))
+	assert.Equal(t,
+		"- Directive  : link-patrol-enable\n"+
+			"  Message    : Unused, there's no link-patrol-disable to close\n"+
+			"  Position   : a.md:4:1\n\n",
+		buf.String(),
+	)
+}
+
+func TestCheckFile_DirectivesAreSkipped(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "a.md")
+	markdown := "<!-- link-patrol-disable-next-line -->\n[gone](gone.md)\n"
+	require.NoError(t, os.WriteFile(path, []byte(markdown), 0o600))
+
+	opts := options{ShowSkipped: true}
+	c := newChecker(opts)
+	defer c.close()
+
+	var buf bytes.Buffer
+	require.NoError(t, checkFile(&buf, path, c, opts))
+	assert.Contains(t, buf.String(),
+		"Message    : Skipped, disabled by link-patrol-disable-next-line at line 1\n",
+	)
+}
@@ -0,0 +1,205 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// isMarkdown reports whether the file name has a markdown extension.
+func isMarkdown(name string) bool {
+	return strings.HasSuffix(name, ".md")
+}
+
+// isGlob reports whether the path contains any glob meta characters.
+func isGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// globBase returns the longest leading directory of a glob pattern that
+// doesn't contain any meta characters. That's where the walk starts.
+func globBase(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	var base []string
+	for _, seg := range segments[:len(segments)-1] {
+		if isGlob(seg) {
+			break
+		}
+		base = append(base, seg)
+	}
+
+	if len(base) == 0 {
+		return "."
+	}
+	if len(base) == 1 && base[0] == "" {
+		return "/"
+	}
+	return strings.Join(base, "/")
+}
+
+// matchGlob reports whether name matches the slash separated pattern.
+// In addition to the path.Match syntax, a "**" segment matches zero or
+// more directories.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Collapse consecutive "**" and try every possible split.
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range name {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchAny reports whether the file matches one of the patterns. Patterns
+// without a slash are matched against the base name as well so that
+// "CHANGELOG.md" excludes the file in every directory.
+func matchAny(patterns []string, name string) bool {
+	name = filepath.ToSlash(filepath.Clean(name))
+	for _, p := range patterns {
+		p = path.Clean(filepath.ToSlash(p))
+		if matchGlob(p, name) {
+			return true
+		}
+		if !strings.Contains(p, "/") && matchGlob(p, path.Base(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// validatePatterns returns an error for the first malformed glob pattern.
+func validatePatterns(patterns []string) error {
+	for _, p := range patterns {
+		if _, err := path.Match(filepath.ToSlash(p), ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// walkMarkdown recursively collects markdown files under root for which
+// keep returns true. Hidden directories such as .git are skipped.
+func walkMarkdown(root string, keep func(string) bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isMarkdown(p) && keep(p) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory: %w", err)
+	}
+	return files, nil
+}
+
+// collectFiles expands the provided paths into the list of files to check.
+// A path can be a file, a directory that's walked recursively, or a glob
+// pattern like docs/**/*.md. Directories and globs only yield markdown files
+// while explicit files are kept as is so that they can be reported later.
+// The include and exclude patterns filter the result.
+func collectFiles(paths, include, exclude []string) ([]string, error) {
+	for _, patterns := range [][]string{paths, include, exclude} {
+		if err := validatePatterns(patterns); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		files []string
+		seen  = make(map[string]bool)
+	)
+
+	add := func(p string) {
+		p = filepath.Clean(p)
+		if seen[p] {
+			return
+		}
+		if len(include) > 0 && !matchAny(include, p) {
+			return
+		}
+		if matchAny(exclude, p) {
+			return
+		}
+		seen[p] = true
+		files = append(files, p)
+	}
+
+	for _, p := range paths {
+		if isGlob(p) {
+			pattern := path.Clean(filepath.ToSlash(p))
+			matches, err := walkMarkdown(
+				filepath.FromSlash(globBase(pattern)),
+				func(name string) bool {
+					return matchGlob(pattern, filepath.ToSlash(name))
+				},
+			)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			sort.Strings(matches)
+			for _, m := range matches {
+				add(m)
+			}
+			continue
+		}
+
+		info, err := os.Stat(p)
+		if err == nil && info.IsDir() {
+			matches, err := walkMarkdown(p, func(string) bool { return true })
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range matches {
+				add(m)
+			}
+			continue
+		}
+
+		// Regular files and paths that can't be read are passed along as
+		// is. Reading them later surfaces the error for that file.
+		add(p)
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("no markdown files found")
+	}
+	return files, nil
+}
@@ -0,0 +1,143 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeTree creates the files relative to root and returns root
+func makeTree(t *testing.T, files ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, f := range files {
+		p := filepath.Join(root, filepath.FromSlash(f))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte("# "+f), 0o600))
+	}
+	return root
+}
+
+func TestMatchGlob(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.md", "README.md", true},
+		{"*.md", "docs/README.md", false},
+		{"docs/*.md", "docs/a.md", true},
+		{"docs/**/*.md", "docs/a.md", true},
+		{"docs/**/*.md", "docs/x/y/a.md", true},
+		{"docs/**/*.md", "other/a.md", false},
+		{"**", "a/b/c", true},
+		{"docs/**", "docs/a/b.md", true},
+		{"**/vendor/**", "a/vendor/b.md", true},
+		{"docs/?.md", "docs/ab.md", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchGlob(tt.pattern, tt.name))
+		})
+	}
+}
+
+func TestGlobBase(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "docs", globBase("docs/**/*.md"))
+	assert.Equal(t, ".", globBase("*.md"))
+	assert.Equal(t, "a/b", globBase("a/b/c*.md"))
+	assert.Equal(t, "/", globBase("/*.md"))
+}
+
+func TestCollectFiles_Directory(t *testing.T) {
+	t.Parallel()
+	root := makeTree(t,
+		"README.md",
+		"docs/a.md",
+		"docs/nested/b.md",
+		"docs/image.png",
+		".git/ignored.md",
+	)
+
+	files, err := collectFiles([]string{root}, nil, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		filepath.Join(root, "README.md"),
+		filepath.Join(root, "docs", "a.md"),
+		filepath.Join(root, "docs", "nested", "b.md"),
+	}, files)
+}
+
+func TestCollectFiles_Glob(t *testing.T) {
+	t.Parallel()
+	root := makeTree(t, "README.md", "docs/a.md", "docs/nested/b.md")
+	pattern := filepath.ToSlash(root) + "/docs/**/*.md"
+
+	files, err := collectFiles([]string{pattern}, nil, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		filepath.Join(root, "docs", "a.md"),
+		filepath.Join(root, "docs", "nested", "b.md"),
+	}, files)
+}
+
+func TestCollectFiles_IncludeExclude(t *testing.T) {
+	t.Parallel()
+	root := makeTree(t,
+		"README.md",
+		"CHANGELOG.md",
+		"docs/a.md",
+		"docs/vendor/b.md",
+	)
+
+	files, err := collectFiles(
+		[]string{root},
+		[]string{"**/docs/**", "README.md"},
+		[]string{"**/vendor/**"},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		filepath.Join(root, "README.md"),
+		filepath.Join(root, "docs", "a.md"),
+	}, files)
+}
+
+func TestCollectFiles_Deduplicates(t *testing.T) {
+	t.Parallel()
+	root := makeTree(t, "a.md")
+	file := filepath.Join(root, "a.md")
+
+	files, err := collectFiles([]string{root, file, file}, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{file}, files)
+}
+
+func TestCollectFiles_KeepsExplicitFiles(t *testing.T) {
+	t.Parallel()
+	files, err := collectFiles([]string{"doesntexist.md", "notes.txt"}, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"doesntexist.md", "notes.txt"}, files)
+}
+
+func TestCollectFiles_NoMatches(t *testing.T) {
+	t.Parallel()
+	root := makeTree(t, "image.png")
+
+	_, err := collectFiles([]string{root}, nil, nil)
+	require.EqualError(t, err, "no markdown files found")
+}
+
+func TestCollectFiles_InvalidPattern(t *testing.T) {
+	t.Parallel()
+	_, err := collectFiles([]string{"."}, []string{"[a"}, nil)
+	require.ErrorContains(t, err, `invalid pattern "[a"`)
+}
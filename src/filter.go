@@ -0,0 +1,126 @@
+package src
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// regexPrefix marks a URL pattern as a regular expression instead of a glob.
+const regexPrefix = "re:"
+
+// urlPattern matches link URLs against a glob or a regular expression.
+type urlPattern struct {
+	raw string
+	re  *regexp.Regexp
+}
+
+// compileURLPattern compiles a pattern. Patterns that start with re: are
+// regular expressions that match anywhere in the URL. Anything else is a
+// glob that has to match the whole URL, where * matches any run of
+// characters, slashes included, and ? matches a single character.
+func compileURLPattern(raw string) (urlPattern, error) {
+	if expr, ok := strings.CutPrefix(raw, regexPrefix); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return urlPattern{}, fmt.Errorf("invalid pattern %q: %w", raw, err)
+		}
+		return urlPattern{raw: raw, re: re}, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range raw {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return urlPattern{raw: raw, re: regexp.MustCompile(sb.String())}, nil
+}
+
+// linkFilter decides which links are checked. When include patterns are
+// set, only links matching one of them are checked, and links matching an
+// exclude pattern are never checked.
+type linkFilter struct {
+	include []urlPattern
+	exclude []urlPattern
+}
+
+func newLinkFilter(include, exclude []string) (*linkFilter, error) {
+	f := &linkFilter{}
+	for _, raw := range include {
+		p, err := compileURLPattern(raw)
+		if err != nil {
+			return nil, fmt.Errorf("include: %w", err)
+		}
+		f.include = append(f.include, p)
+	}
+	for _, raw := range exclude {
+		p, err := compileURLPattern(raw)
+		if err != nil {
+			return nil, fmt.Errorf("exclude: %w", err)
+		}
+		f.exclude = append(f.exclude, p)
+	}
+	return f, nil
+}
+
+// skipReason returns why url is skipped, or an empty string if it has to be
+// checked. A nil filter checks every link.
+func (f *linkFilter) skipReason(url string) string {
+	if f == nil {
+		return ""
+	}
+
+	if len(f.include) > 0 {
+		included := false
+		for _, p := range f.include {
+			if p.re.MatchString(url) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return "Skipped, doesn't match any include pattern"
+		}
+	}
+
+	for _, p := range f.exclude {
+		if p.re.MatchString(url) {
+			return "Skipped, matches exclude pattern " + p.raw
+		}
+	}
+	return ""
+}
+
+// split separates the links that have to be checked from the skipped ones,
+// either by the filter or by an inline directive.
+func (f *linkFilter) split(links []linkOccurrence) ([]linkOccurrence, []linkOccurrence) {
+	var kept, skipped []linkOccurrence
+	for _, link := range links {
+		if link.SkipReason != "" || f.skipReason(link.URL) != "" {
+			skipped = append(skipped, link)
+		} else {
+			kept = append(kept, link)
+		}
+	}
+	return kept, skipped
+}
+
+// skippedRecord returns the linkRecord shown for a link that wasn't checked.
+func skippedRecord(url string, target linkTarget, reason string) linkRecord {
+	return linkRecord{
+		Location: url,
+		Target:   target,
+		OK:       false,
+		Severity: severitySkipped,
+		Skipped:  true,
+		Message:  reason,
+	}
+}
@@ -0,0 +1,140 @@
+package src
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileURLPattern(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		pattern string
+		url     string
+		want    bool
+	}{
+		{"http://localhost*", "http://localhost:8080/a", true},
+		{"http://localhost*", "https://localhost", false},
+		{"https://*.internal.example.com/*", "https://ci.internal.example.com/job/1", true},
+		{"https://*.internal.example.com/*", "https://example.com/", false},
+		{"https://example.com/a?c", "https://example.com/abc", true},
+		{"https://example.com/a.c", "https://example.com/abc", false},
+		{"docs/*.md", "docs/a/b.md", true},
+		{`re:^https?://(localhost|127\.0\.0\.1)`, "http://127.0.0.1:3000", true},
+		{`re:twitter\.com`, "https://twitter.com/x", true},
+		{`re:(?i)FLAKY`, "https://flaky.example.com", true},
+		{`re:^https://`, "http://example.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.url, func(t *testing.T) {
+			p, err := compileURLPattern(tt.pattern)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, p.re.MatchString(tt.url))
+		})
+	}
+}
+
+func TestNewLinkFilter_InvalidRegex(t *testing.T) {
+	t.Parallel()
+	_, err := newLinkFilter(nil, []string{"re:("})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `exclude: invalid pattern "re:("`)
+
+	_, err = newLinkFilter([]string{"re:[a"}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `include: invalid pattern "re:[a"`)
+}
+
+func TestLinkFilter_SkipReason(t *testing.T) {
+	t.Parallel()
+	f, err := newLinkFilter(
+		[]string{"https://*"},
+		[]string{"https://localhost*", `re:example\.org`},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "", f.skipReason("https://example.com"))
+	assert.Equal(t,
+		"Skipped, doesn't match any include pattern",
+		f.skipReason("http://example.com"),
+	)
+	assert.Equal(t,
+		"Skipped, matches exclude pattern https://localhost*",
+		f.skipReason("https://localhost:8080"),
+	)
+	assert.Equal(t,
+		`Skipped, matches exclude pattern re:example\.org`,
+		f.skipReason("https://www.example.org/a"),
+	)
+
+	// A nil filter checks everything
+	var none *linkFilter
+	assert.Equal(t, "", none.skipReason("http://localhost"))
+}
+
+func TestLinkFilter_Split(t *testing.T) {
+	t.Parallel()
+	f, err := newLinkFilter(nil, []string{"http://localhost*"})
+	require.NoError(t, err)
+
+	links := linksOf("https://example.com", "http://localhost:3000", "docs/a.md")
+	kept, skipped := f.split(links)
+	assert.Equal(t, []string{"https://example.com", "docs/a.md"}, urlsOf(kept))
+	assert.Equal(t, []string{"http://localhost:3000"}, urlsOf(skipped))
+}
+
+func TestPrintSkipped(t *testing.T) {
+	t.Parallel()
+	f, err := newLinkFilter(nil, []string{"http://localhost*"})
+	require.NoError(t, err)
+
+	links := linksOf("http://localhost:3000", "http://localhost:3000")
+	var buf bytes.Buffer
+	require.NoError(t, printSkipped(&buf, skippedRecords(links, f)))
+	assert.Equal(t,
+		"- Location   : http://localhost:3000\n"+
+			"  Status Code: -\n"+
+			"  OK         : false\n"+
+			"  Severity   : skipped\n"+
+			"  Skipped    : true\n"+
+			"  Message    : Skipped, matches exclude pattern http://localhost*\n"+
+			"  Attempt    : 0\n"+
+			"  Position   : test.md:1:1\n"+
+			"               test.md:2:1\n\n",
+		buf.String(),
+	)
+}
+
+func TestCheckFile_SkipsFilteredLinks(t *testing.T) {
+	t.Parallel()
+	root := makeTree(t, "docs/b.md")
+	path := filepath.Join(root, "docs", "a.md")
+	content := "[b](b.md) [dev](http://localhost:1/) [gone](missing.md)\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	filter, err := newLinkFilter(nil, []string{"http://localhost*", "missing.md"})
+	require.NoError(t, err)
+	opts := options{Timeout: time.Second, MaxRetries: 1, Filter: filter}
+
+	// Skipped links aren't checked, so the broken ones don't fail the file
+	c := newChecker(opts)
+	defer c.close()
+	var buf bytes.Buffer
+	require.NoError(t, checkFile(&buf, path, c, opts))
+	assert.Contains(t, buf.String(), "- Location   : b.md\n")
+	assert.NotContains(t, buf.String(), "localhost")
+	assert.NotContains(t, buf.String(), "missing.md")
+
+	// With ShowSkipped they're printed as skipped records
+	opts.ShowSkipped = true
+	buf.Reset()
+	require.NoError(t, checkFile(&buf, path, c, opts))
+	assert.Contains(t, buf.String(), "- Location   : http://localhost:1/\n")
+	assert.Contains(t, buf.String(), "Skipped, matches exclude pattern missing.md")
+}
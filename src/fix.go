@@ -0,0 +1,441 @@
+package src
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/urfave/cli/v2"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// destination is where the URL of a link is written in the source: in the
+// parentheses of an inline link or image, or in a reference definition.
+// Start and End are byte offsets and Angled tells whether the URL is
+// wrapped in <>. Line and Column locate the link or definition, and Label
+// is the normalized label of a definition.
+type destination struct {
+	URL    string
+	Start  int
+	End    int
+	Angled bool
+	Line   int
+	Column int
+	Label  string
+}
+
+// destinationAt returns the destination that starts after the whitespace at
+// offset i, or false if the source there isn't a plain destination.
+func destinationAt(source []byte, i int) (destination, bool) {
+	for i < len(source) && (source[i] == ' ' || source[i] == '\t' || source[i] == '\n') {
+		i++
+	}
+	if i >= len(source) {
+		return destination{}, false
+	}
+
+	if source[i] == '<' {
+		end := bytes.IndexAny(source[i+1:], ">\n")
+		if end < 0 || source[i+1+end] != '>' {
+			return destination{}, false
+		}
+		return destination{Start: i + 1, End: i + 1 + end, Angled: true}, true
+	}
+
+	depth, j := 0, i
+loop:
+	for ; j < len(source); j++ {
+		switch source[j] {
+		case '\\':
+			j++
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				break loop
+			}
+			depth--
+		case ' ', '\t', '\n', '\r':
+			break loop
+		}
+	}
+	return destination{Start: i, End: min(j, len(source))}, true
+}
+
+// findDestinations returns the destinations of the inline links, images and
+// reference definitions of the markdown. Destinations whose source doesn't
+// match the parsed URL byte for byte, like the ones with escapes or
+// entities, are left out so that they're never rewritten.
+func findDestinations(markdown []byte) ([]destination, error) {
+	document := goldmark.DefaultParser().Parse(text.NewReader(markdown))
+	pos := newPosition(markdown)
+
+	var dests []destination
+	add := func(node ast.Node, url []byte, separator byte, label string) {
+		start := max(node.Pos(), 0)
+		end := labelEnd(markdown, start)
+		if end < 0 || end+1 >= len(markdown) || markdown[end+1] != separator {
+			return
+		}
+		d, ok := destinationAt(markdown, end+2)
+		if !ok || string(markdown[d.Start:d.End]) != string(url) {
+			return
+		}
+		d.URL = string(url)
+		d.Label = label
+		d.Line, d.Column = pos.at(start)
+		dests = append(dests, d)
+	}
+
+	if err := ast.Walk(
+		document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+			if entering {
+				switch n := node.(type) {
+				case *ast.Link:
+					add(n, n.Destination, '(', "")
+				case *ast.Image:
+					add(n, n.Destination, '(', "")
+				case *ast.LinkReferenceDefinition:
+					add(n, n.Destination, ':', util.ToLinkReference(n.Label))
+				}
+			}
+			return ast.WalkContinue, nil
+		}); err != nil {
+		return nil, fmt.Errorf("failed to traverse markdown AST: %w", err)
+	}
+
+	// Reference definitions come first in the AST, edits go in source order.
+	sort.Slice(dests, func(i, j int) bool { return dests[i].Start < dests[j].Start })
+	return dests, nil
+}
+
+// replacementText returns how url is written in place of a destination.
+// URLs with unbalanced parentheses are wrapped in <> unless they already
+// are.
+func replacementText(url string, angled bool) string {
+	if !angled && strings.Count(url, "(") != strings.Count(url, ")") {
+		return "<" + url + ">"
+	}
+	return url
+}
+
+// linkFix is a destination that's rewritten to a new URL.
+type linkFix struct {
+	destination
+	Target string
+}
+
+// rewriteLinks replaces the destinations whose URL maps to a target and
+// returns the new source along with the fixes it made. Only the bytes of
+// the destinations change.
+func rewriteLinks(
+	markdown []byte,
+	dests []destination,
+	targets map[string]string,
+) ([]byte, []linkFix) {
+	var (
+		out   bytes.Buffer
+		fixes []linkFix
+		last  int
+	)
+	for _, d := range dests {
+		target, ok := targets[d.URL]
+		if !ok || d.Start < last {
+			continue
+		}
+		out.Write(markdown[last:d.Start])
+		out.WriteString(replacementText(target, d.Angled))
+		last = d.End
+		fixes = append(fixes, linkFix{d, target})
+	}
+	if fixes == nil {
+		return markdown, nil
+	}
+	out.Write(markdown[last:])
+	return out.Bytes(), fixes
+}
+
+// withFragment carries the fragment of url over to target unless the
+// redirect brought its own.
+func withFragment(url, target string) string {
+	_, fragment, ok := strings.Cut(url, "#")
+	if !ok || strings.Contains(target, "#") {
+		return target
+	}
+	return target + "#" + fragment
+}
+
+// redirectTargets checks the HTTP links on the checker's pool and maps the
+// URLs that are permanently redirected to the URL they should be replaced
+// with.
+func redirectTargets(links []linkOccurrence, c *checker) map[string]string {
+	var (
+		wg      sync.WaitGroup
+		mutex   sync.Mutex
+		targets = make(map[string]string)
+	)
+
+	urls, _ := groupLinks(links)
+	for _, url := range urls {
+		if !isHTTP(url) {
+			continue
+		}
+		wg.Add(1)
+
+		c.pool.submit(func() {
+			defer wg.Done()
+
+			// Failed records keep their redirects, but their target is dead
+			// or loops.
+			record := c.check(url)
+			if !record.OK {
+				return
+			}
+			target := permanentTarget(record.Redirects)
+			if target == "" {
+				return
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			targets[url] = withFragment(url, target)
+		})
+	}
+
+	wg.Wait()
+	return targets
+}
+
+// unifiedDiff returns the changes between two versions of the file at path
+// as a unified diff with three lines of context. Fixes never add or remove
+// lines, so both versions have the same number of them. Absolute paths lose
+// their leading slash after the a/ and b/ prefixes, like git prints them.
+func unifiedDiff(path string, before, after []byte) string {
+	a, b := splitLines(before), splitLines(after)
+	if len(a) != len(b) {
+		return ""
+	}
+
+	var changed []int
+	for i := range a {
+		if a[i] != b[i] {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return ""
+	}
+
+	const context = 3
+	var sb strings.Builder
+	path = strings.TrimPrefix(path, "/")
+	fmt.Fprintf(&sb, "--- a/%s\n+++ b/%s\n", path, path)
+
+	for i := 0; i < len(changed); {
+		// Changes closer than twice the context share a hunk.
+		j := i
+		for j+1 < len(changed) && changed[j+1]-changed[j] <= 2*context {
+			j++
+		}
+		start := max(changed[i]-context, 0)
+		end := min(changed[j]+context+1, len(a))
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", start+1, end-start, start+1, end-start)
+
+		for k := start; k < end; {
+			if a[k] == b[k] {
+				writeDiffLine(&sb, " ", a[k])
+				k++
+				continue
+			}
+			run := k
+			for run < end && a[run] != b[run] {
+				run++
+			}
+			for _, line := range a[k:run] {
+				writeDiffLine(&sb, "-", line)
+			}
+			for _, line := range b[k:run] {
+				writeDiffLine(&sb, "+", line)
+			}
+			k = run
+		}
+		i = j + 1
+	}
+	return sb.String()
+}
+
+// splitLines splits the source into lines that keep their line endings.
+func splitLines(source []byte) []string {
+	lines := strings.SplitAfter(string(source), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// writeDiffLine writes a line of a hunk, marking a missing final newline.
+func writeDiffLine(sb *strings.Builder, prefix, line string) {
+	sb.WriteString(prefix)
+	sb.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		sb.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// fixFile rewrites the permanently redirected links of a markdown file, or
+// prints the diff of the rewrite when dryRun is set.
+func fixFile(w io.Writer, filepath string, c *checker, opts options, dryRun bool) error {
+	markdown, err := readMarkdown(filepath)
+	if err != nil {
+		return err
+	}
+
+	links, _, err := findLinks(filepath, markdown)
+	if err != nil {
+		return err
+	}
+	links, skipped := opts.Filter.split(links)
+
+	dests, err := findDestinations(markdown)
+	if err != nil {
+		return err
+	}
+
+	// Links disabled by a directive or filtered out stay as they are, and so
+	// do the definitions that only they use.
+	disabled := make(map[[2]int]bool)
+	for _, link := range skipped {
+		disabled[[2]int{link.Line, link.Column}] = true
+	}
+	used := make(map[string]bool)
+	for _, link := range links {
+		if link.Reference != "" {
+			used[link.Reference] = true
+		}
+	}
+	kept := dests[:0]
+	for _, d := range dests {
+		if d.Label != "" && !used[d.Label] || disabled[[2]int{d.Line, d.Column}] {
+			continue
+		}
+		kept = append(kept, d)
+	}
+
+	fixed, fixes := rewriteLinks(markdown, kept, redirectTargets(links, c))
+	if len(fixes) == 0 {
+		return nil
+	}
+
+	if dryRun {
+		_, err := io.WriteString(w, unifiedDiff(filepath, markdown, fixed))
+		return err
+	}
+
+	info, err := os.Stat(filepath)
+	if err != nil {
+		return fmt.Errorf("failed to fix file: %w", err)
+	}
+	if err := os.WriteFile(filepath, fixed, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to fix file: %w", err)
+	}
+	for _, f := range fixes {
+		fmt.Fprintf(
+			w,
+			"Fixed %s:%d:%d: %s -> %s\n",
+			filepath,
+			f.Line,
+			f.Column,
+			f.URL,
+			f.Target,
+		)
+	}
+	return nil
+}
+
+// fix rewrites the permanently redirected links of every file matched by
+// the provided paths.
+func fix(w io.Writer, opts options, dryRun bool, exitFunc func(int)) {
+	files, err := collectFiles(opts.Paths, opts.IncludePaths, opts.ExcludePaths)
+	if err != nil {
+		fmt.Fprintln(w, err)
+		exitFunc(1)
+		return
+	}
+
+	c := newChecker(opts)
+	defer c.close()
+
+	failed := false
+	for _, filepath := range files {
+		if err := fixFile(w, filepath, c, opts, dryRun); err != nil {
+			fmt.Fprintln(w, err)
+			failed = true
+		}
+	}
+
+	if failed {
+		exitFunc(1)
+	}
+}
+
+// fixCommand returns the fix command, which rewrites links that are
+// permanently redirected to their new URL.
+func fixCommand(w io.Writer, exitFunc func(int)) *cli.Command {
+	return &cli.Command{
+		Name:  "fix",
+		Usage: "rewrite permanently redirected links to the URL they redirect to",
+		Flags: append(appFlags(), &cli.BoolFlag{
+			Name:    "dry-run",
+			EnvVars: envVars("dry-run"),
+			Value:   false,
+			Usage:   "print a unified diff instead of rewriting the files",
+		}),
+		Action: func(c *cli.Context) error {
+			if err := inheritFlags(c); err != nil {
+				fmt.Fprintln(w, err)
+				return err
+			}
+			opts, err := contextOptions(w, c)
+			if err != nil {
+				return err
+			}
+			fix(w, opts, c.Bool("dry-run"), exitFunc)
+			return nil
+		},
+	}
+}
+
+// inheritFlags sets the flags of a command that were only given before it,
+// like -f in link-patrol -f docs fix. The command declares its own copy of
+// every global flag, so reading them would otherwise skip the parent's.
+func inheritFlags(c *cli.Context) error {
+	for _, f := range c.Command.Flags {
+		name := f.Names()[0]
+		if c.IsSet(name) {
+			continue
+		}
+		for _, parent := range c.Lineage()[1:] {
+			if !parent.IsSet(name) {
+				continue
+			}
+			values := []string{fmt.Sprint(parent.Value(name))}
+			if _, slice := f.(*cli.StringSliceFlag); slice {
+				values = parent.StringSlice(name)
+			}
+			for _, value := range values {
+				if err := c.Set(name, value); err != nil {
+					return fmt.Errorf("invalid value %q for flag %s: %w", value, name, err)
+				}
+			}
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package src
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindDestinations(t *testing.T) {
+	t.Parallel()
+	markdown := []byte("[a](https://a.com) ![b]( <https://b.com> \"title\")\n" +
+		"[c][ref] `[d](https://d.com)`\n\n" +
+		"[ref]:\n  https://c.com\n")
+
+	dests, err := findDestinations(markdown)
+	require.NoError(t, err)
+
+	var urls []string
+	for _, d := range dests {
+		urls = append(urls, d.URL)
+		assert.Equal(t, d.URL, string(markdown[d.Start:d.End]))
+	}
+	// The code span isn't a link
+	assert.Equal(t, []string{"https://a.com", "https://b.com", "https://c.com"}, urls)
+	assert.True(t, dests[1].Angled)
+	assert.Equal(t, "ref", dests[2].Label)
+	assert.Empty(t, dests[0].Label)
+	assert.Equal(t, [2]int{1, 20}, [2]int{dests[1].Line, dests[1].Column})
+	assert.Equal(t, [2]int{4, 1}, [2]int{dests[2].Line, dests[2].Column})
+}
+
+func TestRewriteLinks(t *testing.T) {
+	t.Parallel()
+	markdown := []byte(
+		"Read [the *docs*](http://a.com \"Docs\") and ![logo](<http://a.com>).\n\n" +
+			"[ref]: http://a.com\n[other]: http://b.com\n",
+	)
+	dests, err := findDestinations(markdown)
+	require.NoError(t, err)
+
+	fixed, fixes := rewriteLinks(markdown, dests, map[string]string{
+		"http://a.com": "https://a.com/wiki/(page",
+	})
+	assert.Len(t, fixes, 3)
+	assert.Equal(t, "Read [the *docs*](<https://a.com/wiki/(page> \"Docs\") and "+
+		"![logo](<https://a.com/wiki/(page>).\n\n"+
+		"[ref]: <https://a.com/wiki/(page>\n[other]: http://b.com\n", string(fixed))
+
+	// Without targets the source is returned as is
+	same, fixes := rewriteLinks(markdown, dests, nil)
+	assert.Nil(t, fixes)
+	assert.Equal(t, markdown, same)
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	t.Parallel()
+	before := []byte("1\n2\n3\n4\nold\n6\n7\n8\n9\n10\n11\n12\n13\nold")
+	after := []byte("1\n2\n3\n4\nnew\n6\n7\n8\n9\n10\n11\n12\n13\nnew")
+
+	assert.Equal(t, "--- a/a.md\n+++ b/a.md\n"+
+		"@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-old\n+new\n 6\n 7\n 8\n"+
+		"@@ -11,4 +11,4 @@\n 11\n 12\n 13\n"+
+		"-old\n\\ No newline at end of file\n+new\n\\ No newline at end of file\n",
+		unifiedDiff("a.md", before, after))
+	assert.Empty(t, unifiedDiff("a.md", before, before))
+
+	// Absolute paths don't end up with a double slash
+	assert.True(t, strings.HasPrefix(
+		unifiedDiff("/docs/a.md", before, after), "--- a/docs/a.md\n+++ b/docs/a.md\n",
+	))
+}
+
+func TestWithFragment(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "https://b.com#x", withFragment("http://a.com#x", "https://b.com"))
+	assert.Equal(t, "https://b.com#y", withFragment("http://a.com#x", "https://b.com#y"))
+	assert.Equal(t, "https://b.com", withFragment("http://a.com", "https://b.com"))
+}
+
+func TestRedirectTargets(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/old":
+				http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+			case "/gone":
+				http.Redirect(w, r, "/dead", http.StatusMovedPermanently)
+			case "/dead":
+				w.WriteHeader(http.StatusNotFound)
+			case "/loop":
+				http.Redirect(w, r, "/loop", http.StatusMovedPermanently)
+			}
+		}),
+	)
+	defer ts.Close()
+
+	c := newChecker(options{Timeout: time.Second, MaxRetries: 1, MaxRedirects: 5})
+	defer c.close()
+
+	// Links whose redirect ends in an error are left alone
+	targets := redirectTargets(linksOf(ts.URL+"/old", ts.URL+"/gone", ts.URL+"/loop"), c)
+	assert.Equal(t, map[string]string{ts.URL + "/old": ts.URL + "/new"}, targets)
+}
+
+func TestCLI_Fix(t *testing.T) {
+	ts := redirectServer()
+	defer ts.Close()
+
+	source := "# Links\n\n" +
+		"[old](" + ts.URL + "/old#intro) and [temp](" + ts.URL + "/temp)\n\n" +
+		"<!-- link-patrol-disable-next-line -->\n" +
+		"[skipped](" + ts.URL + "/old)\n"
+	path := filepath.Join(t.TempDir(), "links.md")
+	require.NoError(t, os.WriteFile(path, []byte(source), 0o600))
+
+	run := func(args ...string) string {
+		var out bytes.Buffer
+		os.Args = append([]string{os.Args[0], "fix", "-f", path}, args...)
+		CLI(&out, "0.1.0-test", func(int) {})
+		return out.String()
+	}
+
+	// A dry run prints the diff and leaves the file alone
+	diff := run("--dry-run")
+	diffPath := strings.TrimPrefix(path, "/")
+	assert.Equal(t, "--- a/"+diffPath+"\n+++ b/"+diffPath+"\n"+
+		"@@ -1,6 +1,6 @@\n # Links\n \n"+
+		"-[old]("+ts.URL+"/old#intro) and [temp]("+ts.URL+"/temp)\n"+
+		"+[old]("+ts.URL+"/moved#intro) and [temp]("+ts.URL+"/temp)\n"+
+		" \n <!-- link-patrol-disable-next-line -->\n [skipped]("+ts.URL+"/old)\n", diff)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, source, string(content))
+
+	// Otherwise only the destination changes
+	out := run()
+	assert.Equal(
+		t,
+		"Fixed "+path+":3:1: "+ts.URL+"/old#intro -> "+ts.URL+"/moved#intro\n",
+		out,
+	)
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "# Links\n\n"+
+		"[old]("+ts.URL+"/moved#intro) and [temp]("+ts.URL+"/temp)\n\n"+
+		"<!-- link-patrol-disable-next-line -->\n"+
+		"[skipped]("+ts.URL+"/old)\n", string(content))
+}
+
+func TestCLI_FixLeavesDisabledDefinitions(t *testing.T) {
+	ts := redirectServer()
+	defer ts.Close()
+
+	source := "<!-- link-patrol-disable-next-line -->\n[x][r]\n\n[y][s]\n\n" +
+		"[r]: " + ts.URL + "/old\n[s]: " + ts.URL + "/old\n"
+	path := filepath.Join(t.TempDir(), "links.md")
+	require.NoError(t, os.WriteFile(path, []byte(source), 0o600))
+
+	var out bytes.Buffer
+	os.Args = []string{os.Args[0], "fix", "-f", path}
+	CLI(&out, "0.1.0-test", func(int) {})
+
+	// Only the definition that a checked link uses is rewritten
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "<!-- link-patrol-disable-next-line -->\n[x][r]\n\n[y][s]\n\n"+
+		"[r]: "+ts.URL+"/old\n[s]: "+ts.URL+"/moved\n", string(content))
+}
+
+func TestCLI_FixDoesNotTouchHealthyFiles(t *testing.T) {
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "links.md")
+	require.NoError(t, os.WriteFile(path, []byte("[ok]("+ts.URL+")\n"), 0o600))
+
+	var out bytes.Buffer
+	os.Args = []string{os.Args[0], "fix", "-f", path}
+	CLI(&out, "0.1.0-test", func(int) {})
+	assert.Empty(t, out.String())
+}
+
+func TestCLI_FixReadsGlobalFlags(t *testing.T) {
+	ts := redirectServer()
+	defer ts.Close()
+
+	source := "[old](" + ts.URL + "/old)\n"
+	path := filepath.Join(t.TempDir(), "links.md")
+	require.NoError(t, os.WriteFile(path, []byte(source), 0o600))
+
+	// Flags work before and after the command
+	for _, args := range [][]string{
+		{"-f", path, "-t", "5s", "fix", "--dry-run"},
+		{"fix", "-f", path, "-t", "5s", "--dry-run"},
+	} {
+		code := 0
+		var out bytes.Buffer
+		os.Args = append([]string{os.Args[0]}, args...)
+		CLI(&out, "0.1.0-test", func(c int) { code = c })
+
+		assert.Equal(t, 0, code, args)
+		diffPath := strings.TrimPrefix(path, "/")
+		assert.Equal(t, "--- a/"+diffPath+"\n+++ b/"+diffPath+"\n@@ -1,1 +1,1 @@\n"+
+			"-[old]("+ts.URL+"/old)\n+[old]("+ts.URL+"/moved)\n", out.String(), args)
+	}
+}
@@ -0,0 +1,199 @@
+package src
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// inGitHubActions reports whether the run happens in a GitHub Actions job,
+// where --format defaults to github.
+func inGitHubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// githubCommands maps severities to the workflow commands that annotate
+// them. Records that are OK or skipped aren't annotated.
+var githubCommands = map[severity]string{
+	severityInfo:    "notice",
+	severityWarning: "warning",
+	severityError:   "error",
+}
+
+// githubRow is a line of the job summary.
+type githubRow struct {
+	Severity severity
+	Position string
+	URL      string
+	Problem  string
+}
+
+// githubReport prints an annotation for every problem as it's found and
+// keeps them for the job summary, which is written to the file named by
+// $GITHUB_STEP_SUMMARY at the end of the run. It's safe for concurrent use.
+type githubReport struct {
+	summary string
+	mu      sync.Mutex
+	rows    []githubRow
+}
+
+// newGitHubReport creates a report that appends its summary to the file at
+// summary, or doesn't write one if it's empty.
+func newGitHubReport(summary string) *githubReport {
+	return &githubReport{summary: summary}
+}
+
+// beginFile does nothing, annotations carry their file.
+func (r *githubReport) beginFile(io.Writer, string) error {
+	return nil
+}
+
+// record annotates every occurrence of a record that has a problem.
+func (r *githubReport) record(w io.Writer, lr linkRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	command, ok := githubCommands[lr.Severity]
+	if !ok {
+		return nil
+	}
+
+	message := lr.Location + ": " + problemDetail(lr)
+	for _, o := range lr.Occurrences {
+		err := writeWorkflowCommand(w, command, message,
+			"file", o.Filepath,
+			"line", fmt.Sprint(o.Line),
+			"col", fmt.Sprint(o.Column),
+			"title", "link-patrol: "+problemRule(lr),
+		)
+		if err != nil {
+			return err
+		}
+		r.rows = append(
+			r.rows,
+			githubRow{lr.Severity, o.String(), lr.Location, problemDetail(lr)},
+		)
+	}
+	return nil
+}
+
+// directive annotates an inline directive that didn't take effect.
+func (r *githubReport) directive(w io.Writer, d directive) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	position := fmt.Sprintf("%s:%d:%d", d.Filepath, d.Line, d.Column)
+	r.rows = append(
+		r.rows,
+		githubRow{severityWarning, position, d.String(), unusedMessage(d)},
+	)
+	return writeWorkflowCommand(w, "warning", d.String()+": "+unusedMessage(d),
+		"file", d.Filepath,
+		"line", fmt.Sprint(d.Line),
+		"col", fmt.Sprint(d.Column),
+		"title", "link-patrol: unused-directive",
+	)
+}
+
+// fileError annotates a file that couldn't be checked.
+func (r *githubReport) fileError(w io.Writer, path string, err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rows = append(r.rows, githubRow{severityError, path, "", err.Error()})
+	return writeWorkflowCommand(w, "error", err.Error(), "file", path)
+}
+
+// finish appends the job summary to the summary file, if any. It's the
+// totals of the run followed by a markdown table of the problems found.
+func (r *githubReport) finish(_ io.Writer, s runSummary) error {
+	if r.summary == "" {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, err := os.OpenFile(r.summary, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write the job summary: %w", err)
+	}
+	defer f.Close()
+
+	var b strings.Builder
+	b.WriteString("## Link patrol\n\n")
+	b.WriteString("| Files | Links | Unique URLs | Errors | Warnings | Notices | Skipped " +
+		"| Retries | Wall time |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- | --- | --- | --- |\n")
+	fmt.Fprintf(&b, "| %d | %d | %d | %d | %d | %d | %d | %d | %s |\n\n",
+		s.Files, s.Links, s.URLs, s.Error, s.Warning, s.Info, s.Skipped, s.Retries,
+		formatMs(s.DurationMs))
+
+	if len(s.SlowestHosts) > 0 {
+		hosts := make([]string, len(s.SlowestHosts))
+		for i, t := range s.SlowestHosts {
+			hosts[i] = fmt.Sprintf("`%s` %s on average over %s",
+				t.Host, formatMs(t.AverageMs), plural(t.Requests, "request"))
+		}
+		fmt.Fprintf(&b, "Slowest hosts: %s.\n\n", strings.Join(hosts, ", "))
+	}
+
+	if len(r.rows) == 0 {
+		b.WriteString("All links are OK.\n")
+	} else {
+		b.WriteString("| Severity | Position | URL | Problem |\n")
+		b.WriteString("| --- | --- | --- | --- |\n")
+		for _, row := range r.rows {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", row.Severity,
+				tableCell(row.Position), tableCell(row.URL), tableCell(row.Problem))
+		}
+	}
+	b.WriteString("\n")
+
+	if _, err := f.WriteString(b.String()); err != nil {
+		return fmt.Errorf("failed to write the job summary: %w", err)
+	}
+	return nil
+}
+
+// tableCell escapes text for a cell of a markdown table.
+func tableCell(text string) string {
+	text = strings.ReplaceAll(text, "|", `\|`)
+	return strings.ReplaceAll(text, "\n", " ")
+}
+
+// writeWorkflowCommand prints a workflow command like
+// ::error file=a.md,line=1::message. Properties come as name and value
+// pairs.
+func writeWorkflowCommand(
+	w io.Writer,
+	command, message string,
+	properties ...string,
+) error {
+	var props []string
+	for i := 0; i+1 < len(properties); i += 2 {
+		props = append(props, properties[i]+"="+escapeProperty(properties[i+1]))
+	}
+	_, err := fmt.Fprintf(
+		w,
+		"::%s %s::%s\n",
+		command,
+		strings.Join(props, ","),
+		escapeData(message),
+	)
+	return err
+}
+
+// escapeData escapes the message of a workflow command.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a property value of a workflow command, which
+// can't hold the separators either.
+func escapeProperty(s string) string {
+	return strings.NewReplacer(
+		"%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C",
+	).Replace(s)
+}
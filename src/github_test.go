@@ -0,0 +1,182 @@
+package src
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteWorkflowCommand(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	err := writeWorkflowCommand(&buf, "error", "100% gone\nreally",
+		"file", "docs/a,b.md", "title", "link-patrol: x")
+	require.NoError(t, err)
+	assert.Equal(t,
+		"::error file=docs/a%2Cb.md,title=link-patrol%3A x::100%25 gone%0Areally\n",
+		buf.String(),
+	)
+}
+
+func TestGitHubReport(t *testing.T) {
+	t.Parallel()
+	summary := filepath.Join(t.TempDir(), "summary.md")
+	r := newGitHubReport(summary)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.record(&buf, linkRecord{
+		Location:    "https://example.com/gone",
+		StatusCode:  404,
+		Message:     "Not Found",
+		Severity:    severityError,
+		Occurrences: linksOf("https://example.com/gone", "https://example.com/gone"),
+	}))
+	require.NoError(t, r.record(&buf, linkRecord{
+		Location:    "https://example.com/ok",
+		StatusCode:  200,
+		OK:          true,
+		Severity:    severityOK,
+		Occurrences: linksOf("https://example.com/ok"),
+	}))
+	require.NoError(t, r.record(&buf, linkRecord{
+		Location:    "https://example.com/a|b",
+		StatusCode:  200,
+		OK:          true,
+		Message:     unverifiedAnchor + " #top: timeout",
+		Severity:    severityInfo,
+		Occurrences: linksOf("https://example.com/a|b"),
+	}))
+	require.NoError(
+		t,
+		r.fileError(&buf, "notes.txt", errors.New("file is not a markdown file")),
+	)
+
+	assert.Equal(t, ""+
+		"::error file=test.md,line=1,col=1,title=link-patrol%3A error-status::"+
+		"https://example.com/gone: Not Found (status 404)\n"+
+		"::error file=test.md,line=2,col=1,title=link-patrol%3A error-status::"+
+		"https://example.com/gone: Not Found (status 404)\n"+
+		"::notice file=test.md,line=1,col=1,title=link-patrol%3A unverified-anchor::"+
+		"https://example.com/a|b: Page OK, couldn't verify anchor #top: "+
+		"timeout (status 200)\n"+
+		"::error file=notes.txt::file is not a markdown file\n",
+		buf.String(),
+	)
+
+	// The summary is appended to what earlier steps wrote
+	require.NoError(t, os.WriteFile(summary, []byte("# Build\n\n"), 0o600))
+	require.NoError(t, r.finish(nil, runSummary{
+		Files:      2,
+		Links:      4,
+		URLs:       3,
+		OK:         1,
+		Info:       1,
+		Error:      2,
+		FileErrors: 1,
+		Retries:    2,
+		SlowestHosts: []hostTiming{
+			{Host: "example.com", Requests: 5, AverageMs: 120.4, TotalMs: 602},
+		},
+		DurationMs: 1534.2,
+	}))
+	content, err := os.ReadFile(summary)
+	require.NoError(t, err)
+	assert.Equal(t, "# Build\n\n"+
+		"## Link patrol\n\n"+
+		"| Files | Links | Unique URLs | Errors | Warnings | Notices | Skipped | Retries "+
+		"| Wall time |\n"+
+		"| --- | --- | --- | --- | --- | --- | --- | --- | --- |\n"+
+		"| 2 | 4 | 3 | 2 | 0 | 1 | 0 | 2 | 1.534s |\n\n"+
+		"Slowest hosts: `example.com` 120ms on average over 5 requests.\n\n"+
+		"| Severity | Position | URL | Problem |\n"+
+		"| --- | --- | --- | --- |\n"+
+		"| error | test.md:1:1 | https://example.com/gone | Not Found (status 404) |\n"+
+		"| error | test.md:2:1 | https://example.com/gone | Not Found (status 404) |\n"+
+		`| info | test.md:1:1 | https://example.com/a\|b | `+
+		"Page OK, couldn't verify anchor #top: timeout (status 200) |\n"+
+		"| error | notes.txt |  | file is not a markdown file |\n\n",
+		string(content),
+	)
+}
+
+func TestGitHubReport_AllOK(t *testing.T) {
+	t.Parallel()
+	summary := filepath.Join(t.TempDir(), "summary.md")
+	r := newGitHubReport(summary)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.record(&buf, linkRecord{OK: true, Severity: severityOK}))
+	require.NoError(t, r.finish(nil, runSummary{Files: 1, Links: 1, URLs: 1, OK: 1}))
+	assert.Empty(t, buf.String())
+
+	content, err := os.ReadFile(summary)
+	require.NoError(t, err)
+	assert.Equal(t, "## Link patrol\n\n"+
+		"| Files | Links | Unique URLs | Errors | Warnings | Notices | Skipped | Retries "+
+		"| Wall time |\n"+
+		"| --- | --- | --- | --- | --- | --- | --- | --- | --- |\n"+
+		"| 1 | 1 | 1 | 0 | 0 | 0 | 0 | 0 | 0s |\n\n"+
+		"All links are OK.\n\n",
+		string(content),
+	)
+
+	// Without $GITHUB_STEP_SUMMARY, there's no summary to write
+	assert.NoError(t, newGitHubReport("").finish(nil, runSummary{}))
+}
+
+func TestCLI_GitHub(t *testing.T) {
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/gone" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}),
+	)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "links.md")
+	require.NoError(t, os.WriteFile(path, []byte(
+		"[ok]("+ts.URL+"/ok)\n\n[gone]("+ts.URL+"/gone)\n",
+	), 0o600))
+	summary := filepath.Join(dir, "summary.md")
+
+	run := func(args ...string) (string, int) {
+		code := 0
+		var out bytes.Buffer
+		os.Args = append([]string{os.Args[0], "-f", path}, args...)
+		CLI(&out, "0.1.0-test", func(c int) { code = c })
+		return out.String(), code
+	}
+
+	// Annotations are the default in GitHub Actions
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv("GITHUB_STEP_SUMMARY", summary)
+	out, code := run()
+	assert.Equal(
+		t,
+		"::error file="+path+",line=3,col=1,title=link-patrol%3A error-status::"+
+			ts.URL+"/gone: Not Found (status 404)\n",
+		out,
+	)
+	assert.Equal(t, 1, code)
+
+	content, err := os.ReadFile(summary)
+	require.NoError(t, err)
+	assert.Contains(
+		t,
+		string(content),
+		"| 1 | 2 | 2 | 1 | 0 | 0 | 0 | 0 |",
+	)
+
+	// An explicit format wins
+	out, _ = run("--format", "tab")
+	assert.Contains(t, out, "Filepath: "+path)
+}
@@ -0,0 +1,104 @@
+package src
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// requestHeaders holds the headers sent with every request of a run: the
+// User-Agent, the --header values and the headers configured per host.
+// Values can reference environment variables like $TOKEN or ${TOKEN} so
+// that secrets stay out of config files. Headers are never printed.
+type requestHeaders struct {
+	userAgent string
+	header    http.Header
+	hosts     map[string]hostConfig
+}
+
+// newRequestHeaders parses the --header values, each formatted like
+// "Name: value".
+func newRequestHeaders(
+	userAgent string,
+	values []string,
+	hosts map[string]hostConfig,
+) (*requestHeaders, error) {
+	h := &requestHeaders{userAgent: userAgent, header: make(http.Header), hosts: hosts}
+	for _, v := range values {
+		name, value, ok := strings.Cut(v, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			// The value isn't quoted as it may hold a secret.
+			return nil, errors.New(
+				`header: expected "Name: value", got a value without a name`,
+			)
+		}
+		addHeader(h.header, name, value)
+	}
+	return h, nil
+}
+
+// addHeader sets a header after expanding the environment variables in its
+// value. Headers whose value ends up empty, like those referencing unset
+// variables, aren't sent.
+func addHeader(header http.Header, name, value string) {
+	if value = strings.TrimSpace(os.ExpandEnv(value)); value != "" {
+		header.Set(name, value)
+	}
+}
+
+// basicAuth returns the Authorization header value for credentials
+// formatted like user:password.
+func basicAuth(credentials string) (string, bool) {
+	if !strings.Contains(credentials, ":") {
+		return "", false
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials)), true
+}
+
+// apply sets the headers for the host of req. The headers of a host win
+// over the --header values, which win over the User-Agent.
+func (h *requestHeaders) apply(req *http.Request) {
+	if h.userAgent != "" {
+		req.Header.Set("User-Agent", h.userAgent)
+	}
+	for name, values := range h.header {
+		req.Header[name] = values
+	}
+	settings := hostSettings(h.hosts, strings.ToLower(req.URL.Hostname()))
+	for name, values := range settings.Headers {
+		req.Header[name] = values
+	}
+}
+
+// transport returns a RoundTripper that adds the headers to every request,
+// nil for the default transport when h is nil. Since headers are added to
+// each request on its own, those of a host aren't forwarded when it
+// redirects to another one.
+func (h *requestHeaders) transport() http.RoundTripper {
+	if h == nil {
+		return nil
+	}
+	return &headerTransport{headers: h, base: http.DefaultTransport}
+}
+
+// headerTransport adds the request headers before handing requests to
+// base.
+type headerTransport struct {
+	headers *requestHeaders
+	base    http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	t.headers.apply(req)
+	return t.base.RoundTrip(req)
+}
+
+// defaultUserAgent is sent when --user-agent isn't set.
+func defaultUserAgent(version string) string {
+	return fmt.Sprintf("link-patrol/%s (+https://github.com/rednafi/link-patrol)", version)
+}
@@ -0,0 +1,144 @@
+package src
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// headerServer records the headers of every request by path. /redirect
+// sends the client to /target on localhost.
+func headerServer() (*httptest.Server, func(string) http.Header) {
+	var mu sync.Mutex
+	seen := make(map[string]http.Header)
+	var ts *httptest.Server
+	ts = httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			seen[r.Host+r.URL.Path] = r.Header.Clone()
+			mu.Unlock()
+
+			if r.URL.Path == "/redirect" {
+				target := strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+				http.Redirect(w, r, target+"/target", http.StatusFound)
+			}
+		}),
+	)
+	return ts, func(key string) http.Header {
+		mu.Lock()
+		defer mu.Unlock()
+		return seen[strings.TrimPrefix(key, "http://")]
+	}
+}
+
+func TestNewRequestHeaders(t *testing.T) {
+	t.Setenv("LP_TEST_TOKEN", "s3cret")
+
+	h, err := newRequestHeaders("bot/1.0", []string{
+		"Accept: text/html",
+		"X-Token: ${LP_TEST_TOKEN}",
+		"X-Unset: $LP_TEST_UNSET",
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.Header{
+		"Accept":  {"text/html"},
+		"X-Token": {"s3cret"},
+	}, h.header)
+
+	// The value may be a secret, so it isn't part of the error
+	_, err = newRequestHeaders("", []string{"Authorization Bearer s3cret"}, nil)
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "s3cret")
+}
+
+func TestBasicAuth(t *testing.T) {
+	t.Parallel()
+	auth, ok := basicAuth("user:pass")
+	assert.True(t, ok)
+	assert.Equal(t, "Basic dXNlcjpwYXNz", auth)
+
+	_, ok = basicAuth("token")
+	assert.False(t, ok)
+}
+
+func TestRequestHeaders_Transport(t *testing.T) {
+	t.Parallel()
+	ts, seen := headerServer()
+	defer ts.Close()
+
+	h, err := newRequestHeaders(
+		"bot/1.0",
+		[]string{"Accept: text/html"},
+		map[string]hostConfig{
+			"127.0.0.1": {Headers: http.Header{
+				"Authorization": {"Bearer s3cret"},
+				"Accept":        {"application/json"},
+			}},
+		},
+	)
+	require.NoError(t, err)
+
+	lr := checkLinkWith(ts.URL+"/redirect", requestConfig{
+		Timeout:      time.Second,
+		MaxRetries:   1,
+		MaxRedirects: defaultMaxRedirects,
+		Headers:      h,
+	})
+	require.True(t, lr.OK)
+
+	first := seen(ts.URL + "/redirect")
+	assert.Equal(t, "bot/1.0", first.Get("User-Agent"))
+	assert.Equal(t, "application/json", first.Get("Accept"))
+	assert.Equal(t, "Bearer s3cret", first.Get("Authorization"))
+
+	// The credentials of a host don't follow it to another one
+	target := seen(strings.Replace(ts.URL, "127.0.0.1", "localhost", 1) + "/target")
+	require.NotNil(t, target)
+	assert.Equal(t, "bot/1.0", target.Get("User-Agent"))
+	assert.Equal(t, "text/html", target.Get("Accept"))
+	assert.Empty(t, target.Get("Authorization"))
+
+	// Without headers, Go's defaults are sent
+	assert.Nil(t, (*requestHeaders)(nil).transport())
+}
+
+func TestCLI_Headers(t *testing.T) {
+	ts, seen := headerServer()
+	defer ts.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "links.md")
+	require.NoError(t, os.WriteFile(path, []byte("[a]("+ts.URL+"/a)"), 0o600))
+
+	var out bytes.Buffer
+	os.Args = []string{os.Args[0], "-f", path}
+	CLI(&out, "0.1.0-test", func(int) {})
+	assert.Equal(t,
+		"link-patrol/0.1.0-test (+https://github.com/rednafi/link-patrol)",
+		seen(ts.URL+"/a").Get("User-Agent"),
+	)
+
+	config := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(config, []byte(
+		"hosts:\n  127.0.0.1:\n    bearer-token-env: LP_TEST_TOKEN\n",
+	), 0o600))
+	t.Setenv("LP_TEST_TOKEN", "s3cret")
+
+	out.Reset()
+	os.Args = []string{
+		os.Args[0], "-f", path, "--config", config, "--user-agent", "bot/1.0", "--json",
+	}
+	CLI(&out, "0.1.0-test", func(int) {})
+	assert.Equal(t, "bot/1.0", seen(ts.URL+"/a").Get("User-Agent"))
+	assert.Equal(t, "Bearer s3cret", seen(ts.URL+"/a").Get("Authorization"))
+	assert.NotContains(t, out.String(), "s3cret")
+}
@@ -0,0 +1,233 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// jsonSchemaVersion is the version of the json and ndjson output. It's
+// bumped when a field is removed or changes meaning, not when one is added.
+const jsonSchemaVersion = 1
+
+// jsonRun is the metadata of a run.
+type jsonRun struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	Tool          jsonTool  `json:"tool"`
+	StartedAt     time.Time `json:"startedAt"`
+	FailOn        severity  `json:"failOn"`
+}
+
+type jsonTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// jsonDirective is an inline directive that didn't take effect.
+type jsonDirective struct {
+	Directive string `json:"directive"`
+	Message   string `json:"message"`
+	Filepath  string `json:"filepath"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+}
+
+func newJSONDirective(d directive) jsonDirective {
+	return jsonDirective{d.String(), unusedMessage(d), d.Filepath, d.Line, d.Column}
+}
+
+// jsonFile holds the results of a markdown file. Error is set when the file
+// couldn't be checked.
+type jsonFile struct {
+	Filepath   string          `json:"filepath"`
+	Error      string          `json:"error,omitempty"`
+	Links      []linkRecord    `json:"links"`
+	Directives []jsonDirective `json:"directives,omitempty"`
+}
+
+// newJSONRun returns the metadata of a run starting now.
+func newJSONRun(version string, failOn severity) jsonRun {
+	if failOn == "" {
+		failOn = severityError
+	}
+	return jsonRun{
+		SchemaVersion: jsonSchemaVersion,
+		Tool:          jsonTool{Name: "link-patrol", Version: version},
+		StartedAt:     time.Now().UTC(),
+		FailOn:        failOn,
+	}
+}
+
+// jsonReport collects the results of every file and prints them as a
+// single JSON document at the end of the run. Skipped links are left out
+// unless showSkipped is set. It's safe for concurrent use.
+type jsonReport struct {
+	run         jsonRun
+	showSkipped bool
+	mu          sync.Mutex
+	files       []*jsonFile
+	byPath      map[string]*jsonFile
+}
+
+func newJSONReport(version string, failOn severity, showSkipped bool) *jsonReport {
+	return &jsonReport{
+		run:         newJSONRun(version, failOn),
+		showSkipped: showSkipped,
+		byPath:      make(map[string]*jsonFile),
+	}
+}
+
+// file returns the section of a file, adding it if needed. The caller must
+// hold the lock.
+func (r *jsonReport) file(filepath string) *jsonFile {
+	f, ok := r.byPath[filepath]
+	if !ok {
+		f = &jsonFile{Filepath: filepath, Links: []linkRecord{}}
+		r.byPath[filepath] = f
+		r.files = append(r.files, f)
+	}
+	return f
+}
+
+// beginFile adds the section of a file, so that files without links have
+// one too.
+func (r *jsonReport) beginFile(_ io.Writer, filepath string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.file(filepath)
+	return nil
+}
+
+// record adds a record to the section of the file it was found in.
+func (r *jsonReport) record(_ io.Writer, lr linkRecord) error {
+	if len(lr.Occurrences) == 0 || lr.Skipped && !r.showSkipped {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f := r.file(lr.Occurrences[0].Filepath)
+	f.Links = append(f.Links, lr)
+	return nil
+}
+
+func (r *jsonReport) directive(_ io.Writer, d directive) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f := r.file(d.Filepath)
+	f.Directives = append(f.Directives, newJSONDirective(d))
+	return nil
+}
+
+func (r *jsonReport) fileError(_ io.Writer, filepath string, err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.file(filepath).Error = err.Error()
+	return nil
+}
+
+// finish prints the document. Links are sorted by where they first appear
+// since they're checked concurrently.
+func (r *jsonReport) finish(w io.Writer, summary runSummary) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	files := make([]jsonFile, len(r.files))
+	for i, f := range r.files {
+		files[i] = *f
+		files[i].Links = append([]linkRecord{}, f.Links...)
+		sort.SliceStable(files[i].Links, func(a, b int) bool {
+			x, y := files[i].Links[a].Occurrences[0], files[i].Links[b].Occurrences[0]
+			return x.Line < y.Line || x.Line == y.Line && x.Column < y.Column
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		jsonRun
+		Files   []jsonFile `json:"files"`
+		Summary runSummary `json:"summary"`
+	}{r.run, files, summary})
+}
+
+// ndjsonReport prints a compact JSON object per line as results come in.
+// Every object has a type: a run line comes first, then the file, link,
+// directive and fileError lines of every file, and a summary line last.
+// Skipped links are left out unless showSkipped is set. It's safe for
+// concurrent use.
+type ndjsonReport struct {
+	run         jsonRun
+	showSkipped bool
+	mu          sync.Mutex
+	started     bool
+}
+
+func newNDJSONReport(version string, failOn severity, showSkipped bool) *ndjsonReport {
+	return &ndjsonReport{run: newJSONRun(version, failOn), showSkipped: showSkipped}
+}
+
+// line prints an object of a type, after the run line if it's the first.
+// The caller must hold the lock.
+func (r *ndjsonReport) line(w io.Writer, kind string, v any) error {
+	if !r.started {
+		r.started = true
+		if err := r.line(w, "run", r.run); err != nil {
+			return err
+		}
+	}
+
+	// The type is spliced into the object so that its fields stay at the
+	// top level.
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode the %s line: %w", kind, err)
+	}
+	head := fmt.Sprintf(`{"type":%q`, kind)
+	if len(body) > 2 {
+		head += ","
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", head, body[1:])
+	return err
+}
+
+func (r *ndjsonReport) beginFile(w io.Writer, filepath string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.line(w, "file", struct {
+		Filepath string `json:"filepath"`
+	}{filepath})
+}
+
+func (r *ndjsonReport) record(w io.Writer, lr linkRecord) error {
+	if lr.Skipped && !r.showSkipped {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.line(w, "link", lr)
+}
+
+func (r *ndjsonReport) directive(w io.Writer, d directive) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.line(w, "directive", newJSONDirective(d))
+}
+
+func (r *ndjsonReport) fileError(w io.Writer, filepath string, err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.line(w, "fileError", struct {
+		Filepath string `json:"filepath"`
+		Error    string `json:"error"`
+	}{filepath, err.Error()})
+}
+
+// finish prints the summary line.
+func (r *ndjsonReport) finish(w io.Writer, summary runSummary) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.line(w, "summary", summary)
+}
@@ -0,0 +1,242 @@
+package src
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ndjsonLines decodes the lines of NDJSON output.
+func ndjsonLines(t *testing.T, out string) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		var v map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &v), line)
+		lines = append(lines, v)
+	}
+	return lines
+}
+
+func TestJSONReport(t *testing.T) {
+	t.Parallel()
+	r := newJSONReport("0.1.0-test", "", true)
+	at := func(file string, line int) []linkOccurrence {
+		return []linkOccurrence{{Filepath: file, Line: line, Column: 1}}
+	}
+
+	require.NoError(t, r.beginFile(nil, "a.md"))
+	require.NoError(t, r.beginFile(nil, "empty.md"))
+	require.NoError(t, r.record(nil, linkRecord{
+		Location:    "https://example.com/gone",
+		StatusCode:  404,
+		Message:     "Not Found",
+		Severity:    severityError,
+		Occurrences: at("a.md", 3),
+	}))
+	require.NoError(t, r.record(nil, linkRecord{
+		Location:    "https://example.com",
+		StatusCode:  200,
+		OK:          true,
+		Message:     "OK",
+		Severity:    severityOK,
+		Occurrences: at("a.md", 1),
+	}))
+	require.NoError(t, r.directive(nil, directive{
+		Kind: disableNextLine, Filepath: "a.md", Line: 5, Column: 1,
+	}))
+	require.NoError(
+		t,
+		r.fileError(nil, "notes.txt", errors.New("file is not a markdown file")),
+	)
+
+	summary := runSummary{
+		Files:      3,
+		Links:      2,
+		URLs:       2,
+		OK:         1,
+		Error:      1,
+		Directives: 1,
+		FileErrors: 1,
+		SlowestHosts: []hostTiming{
+			{Host: "example.com", Requests: 2, AverageMs: 5, TotalMs: 10},
+		},
+		Failed:     true,
+		DurationMs: 12.5,
+	}
+	var buf bytes.Buffer
+	require.NoError(t, r.finish(&buf, summary))
+
+	var doc struct {
+		jsonRun
+		Files   []jsonFile `json:"files"`
+		Summary runSummary `json:"summary"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+
+	assert.Equal(t, jsonSchemaVersion, doc.SchemaVersion)
+	assert.Equal(t, jsonTool{Name: "link-patrol", Version: "0.1.0-test"}, doc.Tool)
+	assert.Equal(t, severityError, doc.FailOn)
+	assert.False(t, doc.StartedAt.IsZero())
+
+	require.Len(t, doc.Files, 3)
+	a := doc.Files[0]
+	assert.Equal(t, "a.md", a.Filepath)
+	require.Len(t, a.Links, 2)
+
+	// Links follow their order in the file
+	assert.Equal(t, "https://example.com", a.Links[0].Location)
+	assert.Equal(t, "https://example.com/gone", a.Links[1].Location)
+	assert.Equal(t, []jsonDirective{{
+		Directive: "link-patrol-disable-next-line",
+		Message:   "Unused, no link is disabled by it",
+		Filepath:  "a.md",
+		Line:      5,
+		Column:    1,
+	}}, a.Directives)
+
+	assert.Equal(t, jsonFile{Filepath: "empty.md", Links: []linkRecord{}}, doc.Files[1])
+	assert.Equal(t, "file is not a markdown file", doc.Files[2].Error)
+
+	assert.Equal(t, summary, doc.Summary)
+}
+
+func TestNDJSONReport(t *testing.T) {
+	t.Parallel()
+	r := newNDJSONReport("0.1.0-test", severityWarning, true)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.beginFile(&buf, "a.md"))
+	require.NoError(t, r.record(&buf, linkRecord{
+		Location:   "https://example.com/old",
+		StatusCode: 200,
+		OK:         true,
+		Message:    "OK",
+		Warning:    "Permanently redirected, update the link to https://example.com/new",
+		Severity:   severityWarning,
+		Occurrences: []linkOccurrence{
+			{Filepath: "a.md", Line: 2, Column: 1, Text: "old", Kind: kindInline},
+		},
+	}))
+	require.NoError(t, r.directive(&buf, directive{
+		Kind: enableBlock, Filepath: "a.md", Line: 4, Column: 1,
+	}))
+	require.NoError(t, r.fileError(&buf, "b.md", errors.New("failed to read file")))
+	require.NoError(t, r.finish(&buf, runSummary{
+		Files: 2, Links: 1, URLs: 1, Warning: 1, FileErrors: 1, Failed: true,
+	}))
+
+	// Every line is a compact object
+	assert.NotContains(t, buf.String(), "  ")
+	lines := ndjsonLines(t, buf.String())
+	require.Len(t, lines, 6)
+
+	var types []any
+	for _, line := range lines {
+		types = append(types, line["type"])
+	}
+	assert.Equal(
+		t,
+		[]any{"run", "file", "link", "directive", "fileError", "summary"},
+		types,
+	)
+
+	assert.Equal(t, float64(jsonSchemaVersion), lines[0]["schemaVersion"])
+	assert.Equal(t, "warning", lines[0]["failOn"])
+	assert.Equal(t, map[string]any{"type": "file", "filepath": "a.md"}, lines[1])
+	assert.Equal(t, "https://example.com/old", lines[2]["location"])
+	assert.Equal(t, "warning", lines[2]["severity"])
+	assert.Equal(t, "link-patrol-enable", lines[3]["directive"])
+	assert.Equal(t, map[string]any{
+		"type": "fileError", "filepath": "b.md", "error": "failed to read file",
+	}, lines[4])
+
+	summary := lines[5]
+	assert.Equal(t, 2.0, summary["files"])
+	assert.Equal(t, 1.0, summary["links"])
+	assert.Equal(t, 1.0, summary["warning"])
+	assert.Equal(t, 1.0, summary["fileErrors"])
+	assert.Equal(t, true, summary["failed"])
+}
+
+func TestCLI_NDJSON(t *testing.T) {
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/gone" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}),
+	)
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "links.md")
+	require.NoError(t, os.WriteFile(path, []byte(
+		"[ok]("+ts.URL+"/ok)\n\n[gone]("+ts.URL+"/gone)\n",
+	), 0o600))
+
+	code := 0
+	var out bytes.Buffer
+	os.Args = []string{os.Args[0], "-f", path, "--format", "ndjson"}
+	CLI(&out, "0.1.0-test", func(c int) { code = c })
+
+	assert.Equal(t, 1, code)
+	lines := ndjsonLines(t, out.String())
+	require.Len(t, lines, 5)
+	assert.Equal(t, "run", lines[0]["type"])
+	assert.Equal(t, "0.1.0-test", lines[0]["tool"].(map[string]any)["version"])
+	assert.Equal(t, path, lines[1]["filepath"])
+
+	statuses := make(map[string]any)
+	for _, line := range lines[2:4] {
+		assert.Equal(t, "link", line["type"])
+		statuses[line["location"].(string)] = line["statusCode"]
+	}
+	assert.Equal(
+		t,
+		map[string]any{ts.URL + "/ok": 200.0, ts.URL + "/gone": 404.0},
+		statuses,
+	)
+
+	assert.Equal(t, "summary", lines[4]["type"])
+	assert.Equal(t, 1.0, lines[4]["ok"])
+	assert.Equal(t, 1.0, lines[4]["error"])
+	assert.Equal(t, 2.0, lines[4]["urls"])
+	hosts := lines[4]["slowestHosts"].([]any)
+	require.Len(t, hosts, 1)
+	assert.Equal(t, "127.0.0.1", hosts[0].(map[string]any)["host"])
+	// The 404 is fetched with GET after HEAD fails, which makes 3 requests
+	assert.Equal(t, 3.0, hosts[0].(map[string]any)["requests"])
+}
+
+func TestCLI_JSONShowSkipped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.md")
+	require.NoError(t, os.WriteFile(path, []byte("[dev](http://localhost:1/)\n"), 0o600))
+
+	run := func(format string, args ...string) string {
+		var out bytes.Buffer
+		os.Args = append([]string{
+			os.Args[0], "-f", path, "--format", format, "--exclude", "http://localhost*",
+		}, args...)
+		CLI(&out, "0.1.0-test", func(int) {})
+		return out.String()
+	}
+
+	// Skipped links are only listed with --show-skipped, but always counted
+	for _, format := range []string{formatJSON, formatNDJSON} {
+		out := run(format)
+		assert.NotContains(t, out, "http://localhost:1/", format)
+		assert.Regexp(t, `"skipped": ?1`, out, format)
+
+		out = run(format, "--show-skipped")
+		assert.Contains(t, out, "http://localhost:1/", format)
+	}
+}
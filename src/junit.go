@@ -0,0 +1,212 @@
+package src
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// junitTestsuites is the root of a JUnit XML report.
+type junitTestsuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestsuite `xml:"testsuite"`
+}
+
+// junitTestsuite holds the links of a markdown file.
+type junitTestsuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestcase `xml:"testcase"`
+}
+
+// junitTestcase is a link. It fails when its severity reaches --fail-on.
+type junitTestcase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+
+	seconds  float64
+	position linkOccurrence
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// junitReport collects a test suite per file and a test case per link, and
+// writes them as a JUnit XML report at the end of the run. It's safe for
+// concurrent use.
+type junitReport struct {
+	failOn severity
+	mu     sync.Mutex
+	files  []string
+	cases  map[string][]junitTestcase
+}
+
+// newJUnitReport creates a report whose test cases fail when their severity
+// reaches failOn, errors when empty.
+func newJUnitReport(failOn severity) *junitReport {
+	if failOn == "" {
+		failOn = severityError
+	}
+	return &junitReport{failOn: failOn, cases: make(map[string][]junitTestcase)}
+}
+
+// beginFile adds the test suite of a file, so that files without links
+// have one too.
+func (r *junitReport) beginFile(_ io.Writer, filepath string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.suite(filepath)
+	return nil
+}
+
+// suite registers the test suite of a file. The caller must hold the lock.
+func (r *junitReport) suite(filepath string) {
+	if _, ok := r.cases[filepath]; !ok {
+		r.files = append(r.files, filepath)
+		r.cases[filepath] = nil
+	}
+}
+
+// record adds the test case of a link to the suite of the file it was
+// found in. Its time is how long the requests for it took.
+func (r *junitReport) record(_ io.Writer, lr linkRecord) error {
+	if len(lr.Occurrences) == 0 {
+		return nil
+	}
+	file := lr.Occurrences[0].Filepath
+
+	var seconds float64
+	for _, a := range lr.Attempts {
+		seconds += a.DurationMs / 1000
+	}
+	tc := junitTestcase{
+		Name:      lr.Location,
+		Classname: file,
+		Time:      junitSeconds(seconds),
+		seconds:   seconds,
+		position:  lr.Occurrences[0],
+	}
+
+	message := lr.Message
+	if lr.Severity == severityWarning && lr.Warning != "" {
+		message = lr.Warning
+	}
+	positions := make([]string, len(lr.Occurrences))
+	for i, o := range lr.Occurrences {
+		positions[i] = "Position: " + o.String()
+	}
+	details := lr.Location + ": " + problemDetail(lr) + "\n" + strings.Join(positions, "\n")
+
+	switch {
+	case lr.Skipped:
+		tc.Skipped = &junitSkipped{Message: message}
+	case lr.Severity.atLeast(r.failOn):
+		tc.Failure = &junitProblem{Message: message, Type: problemRule(lr), Text: details}
+	case lr.Severity == severityInfo || lr.Severity == severityWarning:
+		tc.SystemOut = details
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.suite(file)
+	r.cases[file] = append(r.cases[file], tc)
+	return nil
+}
+
+// directive does nothing, the report only covers links.
+func (r *junitReport) directive(io.Writer, directive) error {
+	return nil
+}
+
+// fileError adds a test case with an error for a file that couldn't be
+// checked.
+func (r *junitReport) fileError(_ io.Writer, filepath string, err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.suite(filepath)
+	r.cases[filepath] = append(r.cases[filepath], junitTestcase{
+		Name:      filepath,
+		Classname: filepath,
+		Time:      junitSeconds(0),
+		Error:     &junitProblem{Message: err.Error()},
+	})
+	return nil
+}
+
+// finish prints the report. Test cases are sorted by where their link first
+// appears since links are checked concurrently. The counts of the report
+// stand in for the summary.
+func (r *junitReport) finish(w io.Writer, _ runSummary) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := junitTestsuites{Name: "link-patrol"}
+	var total float64
+	for _, file := range r.files {
+		cases := append([]junitTestcase{}, r.cases[file]...)
+		sort.SliceStable(cases, func(i, j int) bool {
+			a, b := cases[i].position, cases[j].position
+			return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+		})
+
+		suite := junitTestsuite{Name: file, Tests: len(cases), Cases: cases}
+		var seconds float64
+		for _, tc := range cases {
+			seconds += tc.seconds
+			switch {
+			case tc.Failure != nil:
+				suite.Failures++
+			case tc.Error != nil:
+				suite.Errors++
+			case tc.Skipped != nil:
+				suite.Skipped++
+			}
+		}
+		suite.Time = junitSeconds(seconds)
+		total += seconds
+
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Skipped += suite.Skipped
+		report.Suites = append(report.Suites, suite)
+	}
+	report.Time = junitSeconds(total)
+
+	out, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the JUnit report: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, out)
+	return err
+}
+
+// junitSeconds formats a duration in seconds like JUnit reports do.
+func junitSeconds(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
@@ -0,0 +1,158 @@
+package src
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeJUnit parses a JUnit XML report.
+func decodeJUnit(t *testing.T, doc []byte) junitTestsuites {
+	t.Helper()
+	require.True(t, bytes.HasPrefix(doc, []byte(xml.Header)))
+	var report junitTestsuites
+	require.NoError(t, xml.Unmarshal(doc, &report))
+	return report
+}
+
+func TestJUnitReport(t *testing.T) {
+	t.Parallel()
+	r := newJUnitReport(severityWarning)
+	at := func(file string, line int) []linkOccurrence {
+		return []linkOccurrence{{Filepath: file, Line: line, Column: 1}}
+	}
+
+	require.NoError(t, r.beginFile(nil, "a.md"))
+	require.NoError(t, r.beginFile(nil, "empty.md"))
+	require.NoError(t, r.record(nil, linkRecord{
+		Location:    "https://example.com/gone",
+		StatusCode:  404,
+		Message:     "Not Found",
+		Severity:    severityError,
+		Attempts:    []attemptRecord{{DurationMs: 250}, {DurationMs: 500}},
+		Occurrences: at("a.md", 3),
+	}))
+	require.NoError(t, r.record(nil, linkRecord{
+		Location:    "https://example.com/ok",
+		StatusCode:  200,
+		OK:          true,
+		Message:     "OK",
+		Severity:    severityOK,
+		Attempts:    []attemptRecord{{DurationMs: 125}},
+		Occurrences: at("a.md", 1),
+	}))
+	require.NoError(t, r.record(nil, linkRecord{
+		Location:   "https://example.com/temp",
+		StatusCode: 200,
+		OK:         true,
+		Message:    "OK",
+		Redirects: []redirectHop{
+			{"https://example.com/temp", 302},
+			{"https://b.com", 200},
+		},
+		Severity:    severityInfo,
+		Occurrences: at("a.md", 2),
+	}))
+	require.NoError(t, r.record(nil, linkRecord{
+		Location:    "https://internal.example.com",
+		Skipped:     true,
+		Severity:    severitySkipped,
+		Message:     "excluded by --exclude",
+		Occurrences: at("a.md", 4),
+	}))
+	require.NoError(
+		t,
+		r.fileError(nil, "notes.txt", errors.New("file is not a markdown file")),
+	)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.finish(&buf, runSummary{}))
+	report := decodeJUnit(t, buf.Bytes())
+
+	assert.Equal(t, "link-patrol", report.Name)
+	assert.Equal(t, 5, report.Tests)
+	assert.Equal(t, 1, report.Failures)
+	assert.Equal(t, 1, report.Errors)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, "0.875", report.Time)
+	require.Len(t, report.Suites, 3)
+
+	a := report.Suites[0]
+	assert.Equal(t, "a.md", a.Name)
+	assert.Equal(t, 4, a.Tests)
+	require.Len(t, a.Cases, 4)
+
+	// Test cases follow the order of the links in the file
+	assert.Equal(t, "https://example.com/ok", a.Cases[0].Name)
+	assert.Equal(t, "a.md", a.Cases[0].Classname)
+	assert.Equal(t, "0.125", a.Cases[0].Time)
+	assert.Nil(t, a.Cases[0].Failure)
+
+	assert.Equal(t, "https://example.com/temp", a.Cases[1].Name)
+	assert.Nil(t, a.Cases[1].Failure)
+	assert.Contains(t, a.Cases[1].SystemOut, "https://example.com/temp: OK (status 200)")
+
+	assert.Equal(t, "0.750", a.Cases[2].Time)
+	assert.Equal(t, &junitProblem{
+		Message: "Not Found",
+		Type:    "error-status",
+		Text:    "https://example.com/gone: Not Found (status 404)\nPosition: a.md:3:1",
+	}, a.Cases[2].Failure)
+
+	assert.Equal(t, &junitSkipped{Message: "excluded by --exclude"}, a.Cases[3].Skipped)
+
+	assert.Equal(t, junitTestsuite{
+		Name: "empty.md", Time: "0.000",
+	}, report.Suites[1])
+
+	assert.Equal(t, "notes.txt", report.Suites[2].Name)
+	assert.Equal(t, 1, report.Suites[2].Errors)
+	assert.Equal(t, "file is not a markdown file", report.Suites[2].Cases[0].Error.Message)
+}
+
+func TestCLI_JUnit(t *testing.T) {
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/gone" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}),
+	)
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "links.md")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join([]string{
+		"[ok](" + ts.URL + "/ok)",
+		"[gone](" + ts.URL + "/gone)",
+		"[private](https://internal.example.com)",
+	}, "\n\n")), 0o600))
+
+	code := 0
+	var out bytes.Buffer
+	os.Args = []string{
+		os.Args[0], "-f", path, "--format", "junit", "--exclude", "*internal.example.com*",
+	}
+	CLI(&out, "0.1.0-test", func(c int) { code = c })
+
+	assert.Equal(t, 1, code)
+	report := decodeJUnit(t, out.Bytes())
+	require.Len(t, report.Suites, 1)
+	suite := report.Suites[0]
+	assert.Equal(t, path, suite.Name)
+	assert.Equal(t, 3, suite.Tests)
+	assert.Equal(t, 1, suite.Failures)
+	assert.Equal(t, 1, suite.Skipped)
+	require.Len(t, suite.Cases, 3)
+	assert.Equal(t, ts.URL+"/gone", suite.Cases[1].Name)
+	assert.Equal(t, "Not Found", suite.Cases[1].Failure.Message)
+	assert.NotNil(t, suite.Cases[2].Skipped)
+}
@@ -0,0 +1,213 @@
+package src
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// linkKind describes how a link is written in markdown.
+type linkKind string
+
+const (
+	kindInline    linkKind = "inline"
+	kindReference linkKind = "reference"
+	kindImage     linkKind = "image"
+	kindAutolink  linkKind = "autolink"
+	kindFootnote  linkKind = "footnote"
+)
+
+// linkOccurrence is a single place where a link appears in a markdown file.
+// Line and column are 1-based and the column counts characters, not bytes.
+// Reference is the normalized label of the definition that a reference link
+// or image uses. SkipReason is set when an inline directive disables the
+// link.
+type linkOccurrence struct {
+	URL        string   `json:"-"`
+	Filepath   string   `json:"filepath"`
+	Line       int      `json:"line"`
+	Column     int      `json:"column"`
+	Text       string   `json:"text"`
+	Kind       linkKind `json:"kind"`
+	Reference  string   `json:"-"`
+	SkipReason string   `json:"-"`
+}
+
+// String returns the location as file:line:column.
+func (o linkOccurrence) String() string {
+	return fmt.Sprintf("%s:%d:%d", o.Filepath, o.Line, o.Column)
+}
+
+// position converts byte offsets to line and column numbers.
+type position struct {
+	source     []byte
+	lineStarts []int
+}
+
+func newPosition(source []byte) position {
+	starts := []int{0}
+	for i, b := range source {
+		if b == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return position{source: source, lineStarts: starts}
+}
+
+// at returns the 1-based line and column of the byte offset.
+func (p position) at(offset int) (int, int) {
+	line := sort.Search(len(p.lineStarts), func(i int) bool {
+		return p.lineStarts[i] > offset
+	})
+	start := p.lineStarts[line-1]
+	return line, utf8.RuneCount(p.source[start:offset]) + 1
+}
+
+// labelEnd returns the offset of the bracket closing the link label that
+// opens at start, or -1 if there's none.
+func labelEnd(source []byte, start int) int {
+	depth := 0
+	for i := start; i < len(source); i++ {
+		switch source[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// linkKindAt tells apart inline, reference and footnote links by looking at
+// the source right after the link label.
+func linkKindAt(source []byte, start int, label string) linkKind {
+	end := labelEnd(source, start)
+	if end >= 0 && end+1 < len(source) && source[end+1] == '(' {
+		return kindInline
+	}
+	if strings.HasPrefix(label, "^") {
+		return kindFootnote
+	}
+	return kindReference
+}
+
+// nodeText returns the plain text of an inline node's children.
+func nodeText(n ast.Node, source []byte) string {
+	var sb strings.Builder
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch c := c.(type) {
+		case *ast.Text:
+			sb.Write(c.Value(source))
+		case *ast.String:
+			sb.Write(c.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return sb.String()
+}
+
+// isHTTP reports whether the URL uses the http or https scheme.
+func isHTTP(url string) bool {
+	lower := strings.ToLower(url)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// isLocal reports whether the link points to a local file, i.e. it has no
+// scheme or host. Fragment only links like #installation point to the file
+// they're written in.
+func isLocal(link string) bool {
+	if link == "" || link == "#" || strings.HasPrefix(link, "//") {
+		return false
+	}
+	u, err := url.Parse(link)
+	return err == nil && u.Scheme == ""
+}
+
+// findLinks parses markdown content and returns every HTTP/S and local link
+// along with where it appears in the file, and the inline directives that
+// disable some of them.
+func findLinks(filepath string, markdown []byte) ([]linkOccurrence, []directive, error) {
+	var (
+		links      []linkOccurrence
+		directives []directive
+	)
+
+	// Parse the markdown.
+	reader := text.NewReader(markdown)
+	parser := goldmark.DefaultParser()
+	document := parser.Parse(reader)
+	pos := newPosition(markdown)
+
+	// Add link to result if it's an HTTP/S URL or a local path.
+	addLink := func(
+		node ast.Node,
+		url, label string,
+		kind linkKind,
+		ref *ast.ReferenceLink,
+	) {
+		if !isHTTP(url) && !isLocal(url) {
+			return
+		}
+		line, column := pos.at(max(node.Pos(), 0))
+		link := linkOccurrence{
+			URL:      url,
+			Filepath: filepath,
+			Line:     line,
+			Column:   column,
+			Text:     label,
+			Kind:     kind,
+		}
+		if ref != nil {
+			link.Reference = util.ToLinkReference(ref.Value)
+		}
+		links = append(links, link)
+	}
+
+	// Walk AST to find link and image nodes.
+	if err := ast.Walk(
+		document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+			if entering {
+				switch n := node.(type) {
+				case *ast.Link:
+					label := nodeText(n, markdown)
+					kind := linkKindAt(markdown, max(n.Pos(), 0), label)
+					addLink(n, string(n.Destination), label, kind, n.Reference)
+				case *ast.Image:
+					addLink(
+						n, string(n.Destination), nodeText(n, markdown), kindImage,
+						n.Reference,
+					)
+				case *ast.AutoLink:
+					addLink(
+						n, string(n.URL(markdown)), string(n.Label(markdown)), kindAutolink,
+						nil,
+					)
+				case *ast.HTMLBlock, *ast.RawHTML:
+					directives = append(
+						directives, htmlDirectives(n, filepath, markdown, pos)...,
+					)
+				}
+			}
+			return ast.WalkContinue, nil
+		}); err != nil {
+		return nil, nil, fmt.Errorf("failed to traverse markdown AST: %w", err)
+	}
+
+	applyDirectives(links, directives)
+	return links, directives, nil
+}
@@ -0,0 +1,91 @@
+package src
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// splitFragment splits a link into its path and fragment, dropping any query.
+func splitFragment(link string) (string, string) {
+	p, fragment, _ := strings.Cut(link, "#")
+	p, _, _ = strings.Cut(p, "?")
+	return p, fragment
+}
+
+// resolveLocal returns the filesystem path a local link points to. Relative
+// links are resolved against the directory of the markdown file that
+// contains them and absolute ones against root. Fragment only links point
+// to the file itself.
+func resolveLocal(link, from, root string) string {
+	p, _ := splitFragment(link)
+	if p == "" {
+		return from
+	}
+	if unescaped, err := url.PathUnescape(p); err == nil {
+		p = unescaped
+	}
+
+	if strings.HasPrefix(p, "/") {
+		if root == "" {
+			root = "."
+		}
+		return filepath.Join(root, filepath.FromSlash(p))
+	}
+	return filepath.Join(filepath.Dir(from), filepath.FromSlash(p))
+}
+
+// checkLocalLink checks that the file or directory a local link points to
+// exists. If the link has a fragment and the target is a markdown or HTML
+// file, the fragment has to match one of its anchors from the index as well.
+func checkLocalLink(link, from, root string, index *anchorIndex) linkRecord {
+	target := resolveLocal(link, from, root)
+
+	info, err := os.Stat(target)
+	if err != nil {
+		message := err.Error()
+		if os.IsNotExist(err) {
+			message = "File not found: " + target
+		}
+		return linkRecord{
+			Location: link,
+			Target:   targetFile,
+			OK:       false,
+			Message:  message,
+			Attempt:  1,
+		}
+	}
+
+	_, fragment := splitFragment(link)
+	if fragment != "" && index != nil && !info.IsDir() {
+		anchors, err := index.lookup(target)
+		if err != nil {
+			return linkRecord{
+				Location: link,
+				Target:   targetFile,
+				OK:       false,
+				Message:  err.Error(),
+				Attempt:  1,
+			}
+		}
+		if anchors != nil && !hasAnchor(anchors, fragment) {
+			return linkRecord{
+				Location: link,
+				Target:   targetFile,
+				OK:       false,
+				Message:  fmt.Sprintf("Anchor #%s not found in %s", fragment, target),
+				Attempt:  1,
+			}
+		}
+	}
+
+	return linkRecord{
+		Location: link,
+		Target:   targetFile,
+		OK:       true,
+		Message:  "OK",
+		Attempt:  1,
+	}
+}
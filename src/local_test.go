@@ -0,0 +1,108 @@
+package src
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsLocal(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		link string
+		want bool
+	}{
+		{"setup.md", true},
+		{"../guide/setup.md", true},
+		{"./img/diagram.png", true},
+		{"/docs/setup.md", true},
+		{"setup.md#prerequisites", true},
+		{"#installation", true},
+		{"#", false},
+		{"", false},
+		{"https://example.com", false},
+		{"mailto:example@example.com", false},
+		{"//cdn.example.com/a.js", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			assert.Equal(t, tt.want, isLocal(tt.link))
+		})
+	}
+}
+
+func TestResolveLocal(t *testing.T) {
+	t.Parallel()
+	from := filepath.Join("docs", "guide", "index.md")
+
+	assert.Equal(t,
+		filepath.Join("docs", "setup.md"),
+		resolveLocal("../setup.md", from, "."),
+	)
+	assert.Equal(t,
+		filepath.Join("docs", "guide", "img", "a b.png"),
+		resolveLocal("./img/a%20b.png?raw=1", from, "."),
+	)
+	assert.Equal(t,
+		filepath.Join("site", "docs", "setup.md"),
+		resolveLocal("/docs/setup.md#prerequisites", from, "site"),
+	)
+	assert.Equal(t,
+		filepath.Join("docs", "setup.md"),
+		resolveLocal("/docs/setup.md", from, ""),
+	)
+}
+
+func TestCheckLocalLink(t *testing.T) {
+	t.Parallel()
+	root := makeTree(t, "docs/guide/index.md", "docs/setup.md", "img/diagram.png")
+	from := filepath.Join(root, "docs", "guide", "index.md")
+
+	lr := checkLocalLink("../setup.md#prerequisites", from, root, nil)
+	assert.True(t, lr.OK)
+	assert.Equal(t, targetFile, lr.Target)
+	assert.Equal(t, "../setup.md#prerequisites", lr.Location)
+
+	lr = checkLocalLink("/img/diagram.png", from, root, nil)
+	assert.True(t, lr.OK)
+
+	lr = checkLocalLink("../", from, root, nil)
+	assert.True(t, lr.OK, "directories are valid targets")
+
+	lr = checkLocalLink("../missing.md", from, root, nil)
+	assert.False(t, lr.OK)
+	assert.Equal(
+		t, "File not found: "+filepath.Join(root, "docs", "missing.md"), lr.Message,
+	)
+}
+
+func TestCheckLinks_LocalLinks(t *testing.T) {
+	t.Parallel()
+	root := makeTree(t, "docs/a.md", "docs/b.md")
+	from := filepath.Join(root, "docs", "a.md")
+
+	markdown := []byte("[b](b.md) [gone](../gone.md) [b again](./b.md)")
+	links, _, err := findLinks(from, markdown)
+	require.NoError(t, err)
+	require.Len(t, links, 3)
+
+	c := newChecker(options{Root: root})
+	defer c.close()
+
+	var buf bytes.Buffer
+	err = checkLinks(&buf, links, c, severityError)
+	require.EqualError(t, err, "one or more local links are broken")
+
+	output := buf.String()
+	assert.Contains(t, output, "- Location   : b.md\n"+
+		"  Status Code: -\n"+
+		"  OK         : true\n")
+	assert.Contains(t, output, "- Location   : ../gone.md\n"+
+		"  Status Code: -\n"+
+		"  OK         : false\n")
+	assert.Contains(t, output, "Position   : "+from+":1:11\n")
+}
@@ -0,0 +1,103 @@
+package src
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// Request methods accepted by --method. With methodHead, links are checked
+// with HEAD and only fetched with GET when the server doesn't handle HEAD.
+const (
+	methodHead = "head"
+	methodGet  = "get"
+)
+
+// requestMethods lists the values accepted by --method.
+var requestMethods = map[string]bool{methodHead: true, methodGet: true}
+
+// maxDrain caps how much of a response body is read before closing it. Small
+// bodies are drained so that the connection can be reused, large ones aren't
+// worth downloading just for that.
+const maxDrain = 64 << 10
+
+// headFailed reports whether a HEAD request failed in a way that a GET might
+// not, like a 405 or 501, any other error status, or a server that hangs up.
+// Throttled responses and timeouts aren't worth a second request.
+func headFailed(resp *http.Response, err error) bool {
+	if err != nil {
+		return errorKind(err) == errReset
+	}
+	return resp.StatusCode >= 400 && !isThrottled(resp)
+}
+
+// fetchStatus requests url and returns the last response along with a
+// record of every request that was sent, without the attempt number. Unless
+// headFirst is false, HEAD is tried first and GET only when it fails.
+// Response bodies are always drained and closed.
+func fetchStatus(
+	client *http.Client,
+	url string,
+	headFirst bool,
+) (*http.Response, []attemptRecord, error) {
+	var sent []attemptRecord
+	try := func(method string, ranged bool) (*http.Response, error) {
+		started := time.Now()
+		resp, err := send(client, method, url, ranged)
+		record := attemptRecord{
+			Method:     method,
+			DurationMs: milliseconds(time.Since(started)),
+		}
+		if err != nil {
+			record.Error = err.Error()
+		} else {
+			record.StatusCode = resp.StatusCode
+		}
+		sent = append(sent, record)
+		return resp, err
+	}
+
+	if headFirst {
+		resp, err := try(http.MethodHead, false)
+		if !headFailed(resp, err) {
+			return resp, sent, err
+		}
+	}
+
+	// Only the first byte is asked for, so that images and archives aren't
+	// downloaded in full. Servers that can't serve the range of an empty
+	// resource get a plain GET.
+	resp, err := try(http.MethodGet, true)
+	if err == nil && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		resp, err = try(http.MethodGet, false)
+	}
+	return resp, sent, err
+}
+
+// send makes a single request and drains and closes the response body.
+// The response is nil on errors, except for failed redirects. A 206 that
+// answers the ranged request is the whole resource being there, so it's
+// reported as a 200.
+func send(client *http.Client, method, url string, ranged bool) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if ranged {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+
+	// A redirect that fails comes with the response that asked for it, its
+	// body already closed.
+	resp, err := client.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrain))
+	resp.Body.Close()
+	if ranged && resp.StatusCode == http.StatusPartialContent {
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK"
+	}
+	return resp, nil
+}
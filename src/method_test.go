@@ -0,0 +1,150 @@
+package src
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// methodServer records the method of every request and answers HEAD
+// requests with headStatus.
+func methodServer(headStatus int) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var methods []string
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			methods = append(methods, r.Method+" "+r.Header.Get("Range"))
+			mu.Unlock()
+
+			if r.Method == http.MethodHead {
+				w.WriteHeader(headStatus)
+				return
+			}
+			if r.Header.Get("Range") != "" {
+				w.Header().Set("Content-Range", "bytes 0-0/1048576")
+				w.WriteHeader(http.StatusPartialContent)
+				_, _ = w.Write([]byte("x"))
+				return
+			}
+			_, _ = w.Write([]byte(strings.Repeat("x", 1<<20)))
+		}),
+	)
+	return ts, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return methods
+	}
+}
+
+func TestCheckLinkWith_Head(t *testing.T) {
+	t.Parallel()
+	ts, methods := methodServer(http.StatusOK)
+	defer ts.Close()
+
+	lr := checkLinkWith(ts.URL, requestConfig{Timeout: time.Second, MaxRetries: 1})
+	assert.True(t, lr.OK)
+	assert.Equal(t, http.MethodHead, lr.Method)
+	assert.Equal(t, []string{"HEAD "}, methods())
+}
+
+func TestCheckLinkWith_HeadFallback(t *testing.T) {
+	t.Parallel()
+	for _, status := range []int{http.StatusMethodNotAllowed, http.StatusNotImplemented} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			ts, methods := methodServer(status)
+			defer ts.Close()
+
+			lr := checkLinkWith(ts.URL, requestConfig{Timeout: time.Second, MaxRetries: 1})
+			assert.True(t, lr.OK)
+			assert.Equal(t, http.StatusOK, lr.StatusCode)
+			assert.Equal(t, http.MethodGet, lr.Method)
+			assert.Equal(t, []string{"HEAD ", "GET bytes=0-0"}, methods())
+		})
+	}
+}
+
+func TestCheckLinkWith_HeadFallbackSticks(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.WriteHeader(http.StatusBadGateway)
+		}),
+	)
+	defer ts.Close()
+
+	// Only the first attempt tries HEAD, and the fallback is recorded as a
+	// request of its own
+	lr := checkLinkWith(ts.URL, requestConfig{
+		Timeout:      time.Second,
+		MaxRetries:   3,
+		StartBackoff: time.Millisecond,
+		MaxBackoff:   time.Millisecond,
+	})
+	assert.Equal(t, http.StatusBadGateway, lr.StatusCode)
+	assert.Equal(t, 3, lr.Attempt)
+	require.Len(t, lr.Attempts, 4)
+	assert.Equal(t, attemptRecord{
+		Attempt:    1,
+		Method:     http.MethodHead,
+		StatusCode: http.StatusMethodNotAllowed,
+		DurationMs: lr.Attempts[0].DurationMs,
+	}, lr.Attempts[0])
+	for i, a := range lr.Attempts[1:] {
+		assert.Equal(t, i+1, a.Attempt)
+		assert.Equal(t, http.MethodGet, a.Method)
+		assert.Equal(t, http.StatusBadGateway, a.StatusCode)
+	}
+}
+
+func TestCheckLinkWith_MethodGet(t *testing.T) {
+	t.Parallel()
+	ts, methods := methodServer(http.StatusOK)
+	defer ts.Close()
+
+	lr := checkLinkWith(ts.URL, requestConfig{
+		Timeout:    time.Second,
+		MaxRetries: 1,
+		Method:     methodGet,
+	})
+	assert.True(t, lr.OK)
+	assert.Equal(t, http.MethodGet, lr.Method)
+	assert.Equal(t, []string{"GET bytes=0-0"}, methods())
+
+	// The 206 of the ranged request reads as the page being there
+	assert.Equal(t, http.StatusOK, lr.StatusCode)
+	assert.Equal(t, "OK", lr.Message)
+	assert.Equal(t, http.StatusOK, lr.Attempts[0].StatusCode)
+}
+
+func TestFetchStatus_RangeNotSatisfiable(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Range") != "" {
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	defer ts.Close()
+
+	// An empty resource can't serve the first byte, a plain GET can
+	resp, sent, err := fetchStatus(ts.Client(), ts.URL, false)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, sent, 2)
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, sent[0].StatusCode)
+	assert.Equal(t, http.StatusOK, sent[1].StatusCode)
+}
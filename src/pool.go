@@ -0,0 +1,87 @@
+package src
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// workerPool runs jobs on a fixed number of goroutines. Jobs wait in a
+// bounded queue, so submit blocks once every worker is busy and the queue
+// is full.
+type workerPool struct {
+	jobs chan func()
+	wg   sync.WaitGroup
+}
+
+func newWorkerPool(workers, queueSize int) *workerPool {
+	p := &workerPool{jobs: make(chan func(), queueSize)}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			for job := range p.jobs {
+				job()
+			}
+		}()
+	}
+	return p
+}
+
+// submit queues a job, blocking while the queue is full.
+func (p *workerPool) submit(job func()) {
+	p.jobs <- job
+}
+
+// close stops accepting jobs and waits for the queued ones to finish.
+func (p *workerPool) close() {
+	close(p.jobs)
+	p.wg.Wait()
+}
+
+// hostLimiter caps the number of in-flight requests to a single host.
+// A limit below 1 disables the cap.
+type hostLimiter struct {
+	limit int
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+func newHostLimiter(limit int) *hostLimiter {
+	return &hostLimiter{limit: limit, slots: make(map[string]chan struct{})}
+}
+
+// acquire blocks until a slot for the host is free and returns the function
+// that releases it.
+func (h *hostLimiter) acquire(host string) func() {
+	return h.acquireLimit(host, h.limit)
+}
+
+// acquireLimit works like acquire but caps the host at limit instead of the
+// default. The limit of a host is fixed by its first acquire.
+func (h *hostLimiter) acquireLimit(host string, limit int) func() {
+	if limit < 1 {
+		return func() {}
+	}
+
+	h.mu.Lock()
+	slot, ok := h.slots[host]
+	if !ok {
+		slot = make(chan struct{}, limit)
+		h.slots[host] = slot
+	}
+	h.mu.Unlock()
+
+	slot <- struct{}{}
+	return func() { <-slot }
+}
+
+// hostOf returns the lowercased host of a URL or an empty string if the URL
+// can't be parsed.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
@@ -0,0 +1,153 @@
+package src
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// inFlight tracks the current and the highest number of concurrent calls
+type inFlight struct {
+	current atomic.Int32
+	peak    atomic.Int32
+}
+
+func (f *inFlight) enter() {
+	n := f.current.Add(1)
+	for {
+		peak := f.peak.Load()
+		if n <= peak || f.peak.CompareAndSwap(peak, n) {
+			return
+		}
+	}
+}
+
+func (f *inFlight) leave() {
+	f.current.Add(-1)
+}
+
+func TestWorkerPool_BoundsConcurrency(t *testing.T) {
+	t.Parallel()
+	pool := newWorkerPool(3, 1)
+
+	var (
+		f     inFlight
+		wg    sync.WaitGroup
+		count atomic.Int32
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		pool.submit(func() {
+			defer wg.Done()
+			f.enter()
+			defer f.leave()
+			time.Sleep(2 * time.Millisecond)
+			count.Add(1)
+		})
+	}
+	wg.Wait()
+	pool.close()
+
+	assert.Equal(t, int32(20), count.Load())
+	assert.LessOrEqual(t, f.peak.Load(), int32(3))
+}
+
+func TestHostLimiter(t *testing.T) {
+	t.Parallel()
+	limiter := newHostLimiter(2)
+
+	var (
+		f  inFlight
+		wg sync.WaitGroup
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release := limiter.acquire("example.com")
+			defer release()
+			f.enter()
+			defer f.leave()
+			time.Sleep(2 * time.Millisecond)
+		}()
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, f.peak.Load(), int32(2))
+}
+
+func TestHostLimiter_Disabled(t *testing.T) {
+	t.Parallel()
+	limiter := newHostLimiter(0)
+
+	// Without a cap, acquiring never blocks
+	for i := 0; i < 10; i++ {
+		_ = limiter.acquire("example.com")
+	}
+}
+
+func TestHostLimiter_AcquireLimit(t *testing.T) {
+	t.Parallel()
+	limiter := newHostLimiter(0)
+
+	// A per-host limit applies even when the default cap is disabled
+	release := limiter.acquireLimit("example.com", 1)
+	acquired := make(chan struct{})
+	go func() {
+		defer close(acquired)
+		limiter.acquireLimit("example.com", 1)()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second acquire should block until the first is released")
+	case <-time.After(10 * time.Millisecond):
+	}
+	release()
+	<-acquired
+}
+
+func TestHostOf(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "example.com", hostOf("https://Example.COM:8080/a"))
+	assert.Equal(t, "", hostOf(":%"))
+}
+
+func TestChecker_HostConcurrency(t *testing.T) {
+	t.Parallel()
+	var f inFlight
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			f.enter()
+			defer f.leave()
+			time.Sleep(5 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	defer ts.Close()
+
+	c := newChecker(options{
+		Timeout:         time.Second,
+		MaxRetries:      1,
+		StartBackoff:    time.Millisecond,
+		MaxBackoff:      time.Millisecond,
+		Concurrency:     8,
+		HostConcurrency: 2,
+	})
+	defer c.close()
+
+	urls := make([]string, 12)
+	for i := range urls {
+		urls[i] = ts.URL + "/" + string(rune('a'+i))
+	}
+
+	require.NoError(t, checkLinks(io.Discard, linksOf(urls...), c, severityError))
+	assert.Equal(t, int32(2), f.peak.Load())
+}
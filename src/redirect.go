@@ -0,0 +1,83 @@
+package src
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// defaultMaxRedirects is how many redirects are followed unless
+// --max-redirects says otherwise.
+const defaultMaxRedirects = 10
+
+// redirectHop is a single response on the way to the final page.
+type redirectHop struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"statusCode"`
+}
+
+// String formats the hop for the tabular output.
+func (h redirectHop) String() string {
+	return fmt.Sprintf("%d %s", h.StatusCode, h.URL)
+}
+
+// isPermanentRedirect reports whether the status tells clients to update
+// their links.
+func isPermanentRedirect(code int) bool {
+	return code == http.StatusMovedPermanently || code == http.StatusPermanentRedirect
+}
+
+// followRedirects returns a CheckRedirect function that follows up to max
+// redirects and fails on loops. With max set to 0, redirects aren't
+// followed and the redirect response is reported as is.
+func followRedirects(max int) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if max == 0 {
+			return http.ErrUseLastResponse
+		}
+		for _, prev := range via {
+			if prev.URL.String() == req.URL.String() {
+				return fmt.Errorf("redirect loop back to %s", req.URL)
+			}
+		}
+		if len(via) > max {
+			return fmt.Errorf("stopped after %d redirects", max)
+		}
+		return nil
+	}
+}
+
+// redirectChain returns every response that led to resp, resp included, or
+// nil if resp wasn't redirected.
+func redirectChain(resp *http.Response) []redirectHop {
+	var hops []redirectHop
+	for r := resp; r != nil; r = r.Request.Response {
+		hops = append([]redirectHop{{r.Request.URL.String(), r.StatusCode}}, hops...)
+	}
+	if len(hops) < 2 {
+		return nil
+	}
+	return hops
+}
+
+// permanentTarget returns the URL that the permanent redirects at the start
+// of the chain point to, or "" if it doesn't start with one. Temporary
+// redirects further down the chain are left alone.
+func permanentTarget(hops []redirectHop) string {
+	i := 0
+	for i < len(hops)-1 && isPermanentRedirect(hops[i].StatusCode) {
+		i++
+	}
+	if i == 0 {
+		return ""
+	}
+	return hops[i].URL
+}
+
+// redirectWarning suggests replacing the link when it starts with permanent
+// redirects.
+func redirectWarning(hops []redirectHop) string {
+	if target := permanentTarget(hops); target != "" {
+		return "Permanently redirected, update the link to " + target
+	}
+	return ""
+}
@@ -0,0 +1,164 @@
+package src
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// redirectServer redirects /old to /moved permanently, /moved to /new
+// temporarily, and bounces between /ping and /pong.
+func redirectServer() *httptest.Server {
+	return httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/old":
+				http.Redirect(w, r, "/moved", http.StatusMovedPermanently)
+			case "/moved":
+				http.Redirect(w, r, "/new", http.StatusFound)
+			case "/temp":
+				http.Redirect(w, r, "/new", http.StatusTemporaryRedirect)
+			case "/ping":
+				http.Redirect(w, r, "/pong", http.StatusFound)
+			case "/pong":
+				http.Redirect(w, r, "/ping", http.StatusFound)
+			default:
+				w.WriteHeader(http.StatusOK)
+			}
+		}),
+	)
+}
+
+func TestCheckLinkWith_Redirects(t *testing.T) {
+	t.Parallel()
+	ts := redirectServer()
+	defer ts.Close()
+
+	cfg := requestConfig{Timeout: time.Second, MaxRetries: 1, MaxRedirects: 10}
+
+	lr := checkLinkWith(ts.URL+"/old", cfg)
+	assert.True(t, lr.OK)
+	assert.Equal(t, http.StatusOK, lr.StatusCode)
+	assert.Equal(t, []redirectHop{
+		{ts.URL + "/old", http.StatusMovedPermanently},
+		{ts.URL + "/moved", http.StatusFound},
+		{ts.URL + "/new", http.StatusOK},
+	}, lr.Redirects)
+	assert.Equal(
+		t,
+		"Permanently redirected, update the link to "+ts.URL+"/moved",
+		lr.Warning,
+	)
+
+	// Temporary redirects are recorded without a warning
+	lr = checkLinkWith(ts.URL+"/temp", cfg)
+	assert.True(t, lr.OK)
+	assert.Len(t, lr.Redirects, 2)
+	assert.Empty(t, lr.Warning)
+
+	// Links that aren't redirected don't record any hop
+	lr = checkLinkWith(ts.URL+"/new", cfg)
+	assert.Nil(t, lr.Redirects)
+}
+
+func TestCheckLinkWith_RedirectLoop(t *testing.T) {
+	t.Parallel()
+	ts := redirectServer()
+	defer ts.Close()
+
+	lr := checkLinkWith(ts.URL+"/ping", requestConfig{
+		Timeout:      time.Second,
+		MaxRetries:   3,
+		MaxRedirects: 10,
+	})
+	assert.False(t, lr.OK)
+	assert.Equal(t, 0, lr.StatusCode)
+	assert.Contains(t, lr.Message, "redirect loop back to "+ts.URL+"/ping")
+	assert.Len(t, lr.Redirects, 2)
+
+	// A loop isn't retried
+	assert.Equal(t, 1, lr.Attempt)
+}
+
+func TestCheckLinkWith_MaxRedirects(t *testing.T) {
+	t.Parallel()
+	ts := redirectServer()
+	defer ts.Close()
+
+	cfg := requestConfig{Timeout: time.Second, MaxRetries: 1, MaxRedirects: 1}
+	lr := checkLinkWith(ts.URL+"/old", cfg)
+	assert.False(t, lr.OK)
+	assert.Contains(t, lr.Message, "stopped after 1 redirects")
+
+	// With 0, the redirect itself is the result
+	cfg.MaxRedirects = 0
+	lr = checkLinkWith(ts.URL+"/old", cfg)
+	assert.True(t, lr.OK)
+	assert.Equal(t, http.StatusMovedPermanently, lr.StatusCode)
+	assert.Nil(t, lr.Redirects)
+}
+
+func TestChecker_CachedRedirectsStartAtTheLink(t *testing.T) {
+	t.Parallel()
+	ts := redirectServer()
+	defer ts.Close()
+
+	c := newChecker(options{Timeout: time.Second, MaxRetries: 1, MaxRedirects: 10})
+	defer c.close()
+
+	// Both links share a cache entry, but each chain starts at its own URL
+	first := c.check(ts.URL + "/old#x")
+	second := c.check(ts.URL + "/old")
+	assert.Equal(t, ts.URL+"/old#x", first.Redirects[0].URL)
+	assert.Equal(t, ts.URL+"/old", second.Redirects[0].URL)
+	assert.Equal(t, first.Redirects[1:], second.Redirects[1:])
+	assert.Equal(t, http.StatusMovedPermanently, second.Redirects[0].StatusCode)
+}
+
+func TestRedirectWarning(t *testing.T) {
+	t.Parallel()
+	hops := []redirectHop{
+		{"https://a.com", 308},
+		{"https://b.com", 301},
+		{"https://c.com", 302},
+		{"https://d.com", 200},
+	}
+	assert.Equal(
+		t,
+		"Permanently redirected, update the link to https://c.com",
+		redirectWarning(hops),
+	)
+	assert.Empty(t, redirectWarning(hops[2:]))
+	assert.Empty(t, redirectWarning(nil))
+}
+
+func TestPrintLinkRecordTab_Redirects(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	require.NoError(t, printLinkRecordTab(&buf, linkRecord{
+		Location:   "https://a.com",
+		StatusCode: 200,
+		OK:         true,
+		Message:    "OK",
+		Warning:    "Permanently redirected, update the link to https://b.com",
+		Redirects: []redirectHop{
+			{"https://a.com", 301},
+			{"https://b.com", 200},
+		},
+		Attempt: 1,
+	}))
+
+	assert.Equal(t, "- Location   : https://a.com\n"+
+		"  Status Code: 200\n"+
+		"  OK         : true\n"+
+		"  Message    : OK\n"+
+		"  Warning    : Permanently redirected, update the link to https://b.com\n"+
+		"  Redirects  : 301 https://a.com\n"+
+		"               200 https://b.com\n"+
+		"  Attempt    : 1\n\n", buf.String())
+}
@@ -0,0 +1,89 @@
+package src
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxPageSize caps how much of a remote page is read to look for anchors.
+const maxPageSize = 10 << 20
+
+// pageIndex fetches remote HTML pages and keeps their anchors for the rest
+// of the run, so that any number of fragments on the same page cost a single
+// request. It's safe for concurrent use.
+type pageIndex struct {
+	client *http.Client
+	mu     sync.Mutex
+	pages  map[string]*anchorSet
+}
+
+func newPageIndex(timeout time.Duration, transport http.RoundTripper) *pageIndex {
+	return &pageIndex{
+		client: &http.Client{Timeout: timeout, Transport: transport},
+		pages:  make(map[string]*anchorSet),
+	}
+}
+
+// lookup returns the anchors of the page at url. Pages that aren't HTML
+// don't have anchors and lookup returns nil for them.
+func (p *pageIndex) lookup(url string) (map[string]bool, error) {
+	key := normalizeURL(url)
+
+	p.mu.Lock()
+	set, ok := p.pages[key]
+	if !ok {
+		set = &anchorSet{}
+		p.pages[key] = set
+	}
+	p.mu.Unlock()
+
+	set.once.Do(func() {
+		set.anchors, set.err = p.fetch(key)
+	})
+	return set.anchors, set.err
+}
+
+// fetch downloads the page at url and collects its anchors.
+func (p *pageIndex) fetch(url string) (map[string]bool, error) {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	defer func() { _, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrain)) }()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("failed to fetch page: %s", http.StatusText(resp.StatusCode))
+	}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		return nil, nil
+	}
+
+	anchors := make(map[string]bool)
+	htmlAnchors(io.LimitReader(resp.Body, maxPageSize), anchors)
+	return anchors, nil
+}
+
+// remoteFragment returns the fragment of url that can be verified against
+// the anchors of the page. Text fragments (#:~:text=) and client side routes
+// (#!/path or #/path) never match an element, so they're ignored.
+func remoteFragment(url string) string {
+	_, fragment, _ := strings.Cut(url, "#")
+	if strings.HasPrefix(fragment, ":~:") ||
+		strings.HasPrefix(fragment, "!") ||
+		strings.HasPrefix(fragment, "/") {
+		return ""
+	}
+	return fragment
+}
+
+// hasRemoteAnchor reports whether the fragment matches an anchor of the
+// page. GitHub prefixes the ids of rendered headings with user-content-, so
+// those match as well.
+func hasRemoteAnchor(anchors map[string]bool, fragment string) bool {
+	return hasAnchor(anchors, fragment) || hasAnchor(anchors, "user-content-"+fragment)
+}
@@ -0,0 +1,126 @@
+package src
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPageServer serves an HTML page with a few anchors at /page and a plain
+// text file at /plain, counting the requests for each path
+func newPageServer(t *testing.T, hits map[string]*atomic.Int32) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if counter, ok := hits[r.URL.Path]; ok {
+				counter.Add(1)
+			}
+			switch r.URL.Path {
+			case "/page":
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				_, _ = io.WriteString(w, `<html><body>
+<h2 id="install">Install</h2>
+<a name="legacy"></a>
+<h2 id="user-content-usage">Usage</h2>
+</body></html>`)
+			case "/plain":
+				w.Header().Set("Content-Type", "text/plain")
+				_, _ = io.WriteString(w, "id=\"nothing\"")
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}),
+	)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestRemoteFragment(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "install", remoteFragment("https://example.com/#install"))
+	assert.Equal(t, "", remoteFragment("https://example.com/"))
+	assert.Equal(t, "", remoteFragment("https://example.com/#:~:text=hello"))
+	assert.Equal(t, "", remoteFragment("https://example.com/#!/route"))
+	assert.Equal(t, "", remoteFragment("https://example.com/#/route"))
+}
+
+func TestChecker_RemoteAnchors(t *testing.T) {
+	t.Parallel()
+	hits := map[string]*atomic.Int32{"/page": {}, "/plain": {}}
+	ts := newPageServer(t, hits)
+
+	c := newChecker(options{
+		Timeout:       time.Second,
+		MaxRetries:    1,
+		StartBackoff:  time.Millisecond,
+		MaxBackoff:    time.Millisecond,
+		Concurrency:   4,
+		RemoteAnchors: true,
+	})
+	defer c.close()
+
+	tests := []struct {
+		url     string
+		ok      bool
+		message string
+	}{
+		{ts.URL + "/page#install", true, "OK"},
+		{ts.URL + "/page#legacy", true, "OK"},
+		{ts.URL + "/page#usage", true, "OK"},
+		{ts.URL + "/page#gone", false, "Page OK, anchor #gone not found"},
+		{ts.URL + "/page#:~:text=Install", true, "OK"},
+		{ts.URL + "/plain#nothing", true, "OK"},
+		{ts.URL + "/missing#install", false, "Not Found"},
+	}
+
+	for _, tt := range tests {
+		lr := c.check(tt.url)
+		assert.Equal(t, tt.ok, lr.OK, tt.url)
+		assert.Equal(t, tt.message, lr.Message, tt.url)
+	}
+
+	// One request for the status of the page and one for its anchors
+	assert.Equal(t, int32(2), hits["/page"].Load())
+	assert.Equal(t, int32(2), hits["/plain"].Load())
+}
+
+func TestChecker_RemoteAnchorsDisabled(t *testing.T) {
+	t.Parallel()
+	hits := map[string]*atomic.Int32{"/page": {}}
+	ts := newPageServer(t, hits)
+
+	c := newChecker(options{
+		Timeout:      time.Second,
+		MaxRetries:   1,
+		StartBackoff: time.Millisecond,
+		MaxBackoff:   time.Millisecond,
+	})
+	defer c.close()
+
+	lr := c.check(ts.URL + "/page#gone")
+	assert.True(t, lr.OK)
+	assert.Equal(t, int32(1), hits["/page"].Load())
+}
+
+func TestCheckLinks_MissingRemoteAnchorFails(t *testing.T) {
+	t.Parallel()
+	ts := newPageServer(t, map[string]*atomic.Int32{})
+
+	c := newChecker(options{
+		Timeout:       time.Second,
+		MaxRetries:    1,
+		StartBackoff:  time.Millisecond,
+		MaxBackoff:    time.Millisecond,
+		RemoteAnchors: true,
+	})
+	defer c.close()
+
+	err := checkLinks(io.Discard, linksOf(ts.URL+"/page#gone"), c, severityError)
+	require.EqualError(t, err, "one or more anchors are missing")
+}
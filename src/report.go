@@ -0,0 +1,66 @@
+package src
+
+import (
+	"io"
+	"os"
+)
+
+// Output formats accepted by --format. Tab prints every record as it's
+// checked, the others are reporters.
+const (
+	formatTab    = "tab"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatSARIF  = "sarif"
+	formatGitHub = "github"
+	formatJUnit  = "junit"
+)
+
+// outputFormats lists the values accepted by --format.
+var outputFormats = map[string]bool{
+	formatTab:    true,
+	formatJSON:   true,
+	formatNDJSON: true,
+	formatSARIF:  true,
+	formatGitHub: true,
+	formatJUnit:  true,
+}
+
+// reporter receives the results of a run in place of the tabular output.
+// Reporters that write a single document do it in finish, once every link
+// has been checked. They must be safe for concurrent use.
+type reporter interface {
+	// beginFile is called before the links of a file are checked.
+	beginFile(w io.Writer, filepath string) error
+
+	// record reports the result of a link, skipped links included. It's up
+	// to the reporter whether to list them.
+	record(w io.Writer, lr linkRecord) error
+
+	// directive reports an inline directive that didn't take effect.
+	directive(w io.Writer, d directive) error
+
+	// fileError reports a file that couldn't be checked.
+	fileError(w io.Writer, filepath string, err error) error
+
+	// finish is called once the run is over, with its summary.
+	finish(w io.Writer, s runSummary) error
+}
+
+// newReporter returns the reporter of a format, nil for the tabular one.
+// The json and ndjson reporters only list skipped links with showSkipped.
+func newReporter(format, version string, failOn severity, showSkipped bool) reporter {
+	switch format {
+	case formatJSON:
+		return newJSONReport(version, failOn, showSkipped)
+	case formatNDJSON:
+		return newNDJSONReport(version, failOn, showSkipped)
+	case formatSARIF:
+		return newSARIFReport(version)
+	case formatGitHub:
+		return newGitHubReport(os.Getenv("GITHUB_STEP_SUMMARY"))
+	case formatJUnit:
+		return newJUnitReport(failOn)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// defaultRetryOn lists the failures retried unless --retry-on says otherwise.
+var defaultRetryOn = []string{"408", "425", "429", "5xx", "timeout", "reset", "dns"}
+
+// Error kinds that --retry-on accepts next to status codes.
+const (
+	errTimeout = "timeout"
+	errReset   = "reset"
+	errDNS     = "dns"
+)
+
+// statusRange is an inclusive range of HTTP status codes.
+type statusRange struct {
+	Lo, Hi int
+}
+
+// contains reports whether the status code is in the range.
+func (r statusRange) contains(code int) bool {
+	return code >= r.Lo && code <= r.Hi
+}
+
+// parseStatusRange parses a status code like 404, a range like 500-504 or
+// a class like 5xx.
+func parseStatusRange(s string) (statusRange, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if len(s) == 3 && strings.HasSuffix(s, "xx") && s[0] >= '1' && s[0] <= '5' {
+		lo := int(s[0]-'0') * 100
+		return statusRange{lo, lo + 99}, nil
+	}
+
+	loText, hiText, isRange := strings.Cut(s, "-")
+	lo, err := strconv.Atoi(loText)
+	hi := lo
+	if err == nil && isRange {
+		hi, err = strconv.Atoi(hiText)
+	}
+	if err != nil || lo < 100 || hi > 999 || lo > hi {
+		return statusRange{}, fmt.Errorf(
+			"invalid status %q, expected a code like 404, a range like 500-504 or 5xx", s,
+		)
+	}
+	return statusRange{lo, hi}, nil
+}
+
+// retryPolicy decides which failed requests are worth retrying.
+type retryPolicy struct {
+	statuses []statusRange
+	errors   map[string]bool
+}
+
+// newRetryPolicy parses the --retry-on values.
+func newRetryPolicy(values []string) (*retryPolicy, error) {
+	p := &retryPolicy{errors: make(map[string]bool)}
+	for _, v := range values {
+		switch v := strings.TrimSpace(strings.ToLower(v)); v {
+		case errTimeout, errReset, errDNS:
+			p.errors[v] = true
+		default:
+			r, err := parseStatusRange(v)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"retry-on: %w, or one of timeout, reset and dns", err,
+				)
+			}
+			p.statuses = append(p.statuses, r)
+		}
+	}
+	return p, nil
+}
+
+// defaultRetryPolicy is used when no policy is configured.
+var defaultRetryPolicy, _ = newRetryPolicy(defaultRetryOn)
+
+// retryable reports whether a request that ended with resp or err should be
+// retried. A nil policy uses the default one.
+func (p *retryPolicy) retryable(resp *http.Response, err error) bool {
+	if p == nil {
+		p = defaultRetryPolicy
+	}
+	if err != nil {
+		kind := errorKind(err)
+		return kind != "" && p.errors[kind]
+	}
+	for _, r := range p.statuses {
+		if r.contains(resp.StatusCode) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorKind classifies transient request errors as a timeout, a connection
+// reset or a temporary DNS failure. Everything else, like a host that
+// doesn't exist or a certificate that fails verification, gets "".
+func errorKind(err error) string {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout || dnsErr.IsTemporary {
+			return errDNS
+		}
+		return ""
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return errTimeout
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) {
+		return errReset
+	}
+	return ""
+}
@@ -0,0 +1,149 @@
+package src
+
+import (
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStatusRange(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		value string
+		want  statusRange
+		err   bool
+	}{
+		{"404", statusRange{404, 404}, false},
+		{"500-504", statusRange{500, 504}, false},
+		{"5XX", statusRange{500, 599}, false},
+		{" 4xx ", statusRange{400, 499}, false},
+		{"6xx", statusRange{}, true},
+		{"504-500", statusRange{}, true},
+		{"99", statusRange{}, true},
+		{"abc", statusRange{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseStatusRange(tt.value)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewRetryPolicy_Invalid(t *testing.T) {
+	t.Parallel()
+	_, err := newRetryPolicy([]string{"5xx", "sometimes"})
+	assert.ErrorContains(t, err, `retry-on: invalid status "sometimes"`)
+}
+
+func TestRetryPolicy_Retryable(t *testing.T) {
+	t.Parallel()
+	status := func(code int) *http.Response { return &http.Response{StatusCode: code} }
+	urlErr := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://a.com", Err: err}
+	}
+
+	tests := []struct {
+		name string
+		resp *http.Response
+		err  error
+		want bool
+	}{
+		{"408", status(408), nil, true},
+		{"425", status(425), nil, true},
+		{"429", status(429), nil, true},
+		{"503", status(503), nil, true},
+		{"403", status(403), nil, false},
+		{"404", status(404), nil, false},
+		{"410", status(410), nil, false},
+		{"timeout", nil, urlErr(os.ErrDeadlineExceeded), true},
+		{"reset", nil, urlErr(&net.OpError{Op: "read", Err: syscall.ECONNRESET}), true},
+		{"eof", nil, urlErr(io.EOF), true},
+		{
+			"dns temporary",
+			nil,
+			urlErr(&net.DNSError{Err: "server misbehaving", IsTemporary: true}),
+			true,
+		},
+		{
+			"dns not found",
+			nil,
+			urlErr(&net.DNSError{Err: "no such host", IsNotFound: true}),
+			false,
+		},
+		{"tls", nil, urlErr(&x509.UnknownAuthorityError{}), false},
+		{
+			"refused",
+			nil,
+			urlErr(&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}),
+			false,
+		},
+	}
+
+	// A nil policy falls back to the default one
+	var policy *retryPolicy
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, policy.retryable(tt.resp, tt.err))
+		})
+	}
+
+	custom, err := newRetryPolicy([]string{"404", "500-502", "timeout"})
+	require.NoError(t, err)
+	assert.True(t, custom.retryable(status(404), nil))
+	assert.True(t, custom.retryable(status(502), nil))
+	assert.False(t, custom.retryable(status(503), nil))
+	assert.True(t, custom.retryable(nil, urlErr(os.ErrDeadlineExceeded)))
+	assert.False(t, custom.retryable(nil, urlErr(errors.New("read: connection reset"))))
+}
+
+func TestCheckLinkWith_RetryOn(t *testing.T) {
+	t.Parallel()
+	var hits atomic.Int32
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+		}),
+	)
+	defer ts.Close()
+
+	cfg := requestConfig{
+		Timeout:      time.Second,
+		MaxRetries:   3,
+		StartBackoff: time.Millisecond,
+		MaxBackoff:   time.Millisecond,
+		Method:       methodGet,
+	}
+
+	// A 404 is reported after the first attempt
+	lr := checkLinkWith(ts.URL, cfg)
+	assert.Equal(t, http.StatusNotFound, lr.StatusCode)
+	assert.Equal(t, 1, lr.Attempt)
+	assert.Len(t, lr.Attempts, 1)
+	assert.Equal(t, int32(1), hits.Load())
+
+	// Unless the policy asks for it
+	cfg.Retry, _ = newRetryPolicy([]string{"4xx"})
+	lr = checkLinkWith(ts.URL, cfg)
+	assert.Equal(t, 3, lr.Attempt)
+	assert.Equal(t, int32(4), hits.Load())
+}
@@ -0,0 +1,361 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The SARIF version that's written and its JSON schema.
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+// sarifRule is a category of problems. Every result refers to one.
+type sarifRule struct {
+	ID               string             `json:"id"`
+	Name             string             `json:"name"`
+	ShortDescription sarifMessage       `json:"shortDescription"`
+	Default          sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+// sarifRules are the problem categories, in the order of the rules of the
+// report.
+var sarifRules = []sarifRule{
+	newSARIFRule(
+		"error-status",
+		"ErrorStatus",
+		"URL answers with an error status code",
+		"error",
+	),
+	newSARIFRule("unreachable", "Unreachable", "URL can't be reached", "error"),
+	newSARIFRule(
+		"redirect-loop",
+		"RedirectLoop",
+		"URL redirects in a loop or too many times",
+		"error",
+	),
+	newSARIFRule(
+		"missing-anchor",
+		"MissingAnchor",
+		"Anchor of the URL is missing from the page",
+		"error",
+	),
+	newSARIFRule(
+		"broken-local-link",
+		"BrokenLocalLink",
+		"Local file or anchor doesn't exist",
+		"error",
+	),
+	newSARIFRule("throttled", "Throttled", "Host kept throttling requests", "warning"),
+	newSARIFRule(
+		"soft-404",
+		"Soft404",
+		"Page answers 200 but looks like a missing page",
+		"warning",
+	),
+	newSARIFRule(
+		"permanent-redirect",
+		"PermanentRedirect",
+		"URL is permanently redirected",
+		"warning",
+	),
+	newSARIFRule(
+		"accepted-status",
+		"AcceptedStatus",
+		"Status code is accepted as a warning",
+		"warning",
+	),
+	newSARIFRule(
+		"temporary-redirect",
+		"TemporaryRedirect",
+		"URL is temporarily redirected",
+		"note",
+	),
+	newSARIFRule(
+		"unverified-anchor",
+		"UnverifiedAnchor",
+		"Anchor of the URL couldn't be verified",
+		"note",
+	),
+	newSARIFRule(
+		"unused-directive",
+		"UnusedDirective",
+		"Inline directive doesn't disable any link",
+		"warning",
+	),
+}
+
+func newSARIFRule(id, name, description, level string) sarifRule {
+	return sarifRule{
+		ID:               id,
+		Name:             name,
+		ShortDescription: sarifMessage{Text: description},
+		Default:          sarifConfiguration{Level: level},
+	}
+}
+
+// sarifLevels maps severities to SARIF levels. Records that are OK or
+// skipped aren't reported.
+var sarifLevels = map[severity]string{
+	severityInfo:    "note",
+	severityWarning: "warning",
+	severityError:   "error",
+}
+
+// problemRule returns the category of a record that has a problem, the ID of
+// one of the sarifRules.
+func problemRule(lr linkRecord) string {
+	switch lr.Severity {
+	case severityInfo:
+		if strings.HasPrefix(lr.Message, unverifiedAnchor) {
+			return "unverified-anchor"
+		}
+		return "temporary-redirect"
+	case severityWarning:
+		switch {
+		case lr.Throttled:
+			return "throttled"
+		case lr.Soft404:
+			return "soft-404"
+		case permanentTarget(lr.Redirects) != "":
+			return "permanent-redirect"
+		}
+		return "accepted-status"
+	}
+
+	switch {
+	case lr.Target == targetFile:
+		return "broken-local-link"
+	case lr.StatusCode >= 400:
+		return "error-status"
+	case lr.StatusCode > 0:
+		return "missing-anchor"
+	case len(lr.Redirects) > 0:
+		return "redirect-loop"
+	}
+	return "unreachable"
+}
+
+// problemDetail describes the problem of a record, with its warning rather
+// than its message for warnings.
+func problemDetail(lr linkRecord) string {
+	detail := lr.Message
+	if lr.Severity == severityWarning && lr.Warning != "" {
+		detail = lr.Warning
+	}
+	if lr.StatusCode > 0 {
+		detail = fmt.Sprintf("%s (status %d)", detail, lr.StatusCode)
+	}
+	return detail
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// before reports whether the region starts before o. Regions are set for
+// every result.
+func (r *sarifRegion) before(o *sarifRegion) bool {
+	if r.StartLine != o.StartLine {
+		return r.StartLine < o.StartLine
+	}
+	return r.StartColumn < o.StartColumn
+}
+
+type sarifNotification struct {
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+// sarifLocationOf returns the location of a line and column in a file.
+// Relative paths stay relative so that code scanning resolves them against
+// the repository.
+func sarifLocationOf(path string, line, column int) sarifLocation {
+	uri := filepath.ToSlash(path)
+	if filepath.IsAbs(path) {
+		uri = (&url.URL{Scheme: "file", Path: uri}).String()
+	}
+	loc := sarifLocation{
+		PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: uri},
+		},
+	}
+	if line > 0 {
+		loc.PhysicalLocation.Region = &sarifRegion{StartLine: line, StartColumn: column}
+	}
+	return loc
+}
+
+// sarifReport collects the problems of a run and writes them as a single
+// SARIF document once it's done. It's safe for concurrent use.
+type sarifReport struct {
+	version       string
+	mu            sync.Mutex
+	results       []sarifResult
+	notifications []sarifNotification
+}
+
+func newSARIFReport(version string) *sarifReport {
+	return &sarifReport{version: version}
+}
+
+// beginFile does nothing, results carry their file.
+func (r *sarifReport) beginFile(io.Writer, string) error {
+	return nil
+}
+
+// record adds a result per occurrence of the URL of a record. Records that
+// are OK or skipped are left out.
+func (r *sarifReport) record(_ io.Writer, lr linkRecord) error {
+	level, ok := sarifLevels[lr.Severity]
+	if !ok {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, o := range lr.Occurrences {
+		r.addResult(problemRule(lr), level, lr.Location+": "+problemDetail(lr),
+			sarifLocationOf(o.Filepath, o.Line, o.Column))
+	}
+	return nil
+}
+
+// directive adds a result for an inline directive that didn't take effect.
+func (r *sarifReport) directive(_ io.Writer, d directive) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addResult("unused-directive", "warning", d.String()+": "+unusedMessage(d),
+		sarifLocationOf(d.Filepath, d.Line, d.Column))
+	return nil
+}
+
+// addResult appends a result. The caller must hold the lock.
+func (r *sarifReport) addResult(ruleID, level, text string, loc sarifLocation) {
+	index := 0
+	for i, rule := range sarifRules {
+		if rule.ID == ruleID {
+			index = i
+			break
+		}
+	}
+	r.results = append(r.results, sarifResult{
+		RuleID:    ruleID,
+		RuleIndex: index,
+		Level:     level,
+		Message:   sarifMessage{Text: text},
+		Locations: []sarifLocation{loc},
+	})
+}
+
+// fileError adds a notification for a file that couldn't be checked.
+func (r *sarifReport) fileError(_ io.Writer, path string, err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notifications = append(r.notifications, sarifNotification{
+		Level:     "error",
+		Message:   sarifMessage{Text: err.Error()},
+		Locations: []sarifLocation{sarifLocationOf(path, 0, 0)},
+	})
+	return nil
+}
+
+// finish prints the SARIF document, with the summary in the property bag of
+// the run. Results are sorted by location since links are checked
+// concurrently.
+func (r *sarifReport) finish(w io.Writer, summary runSummary) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	results := append([]sarifResult{}, r.results...)
+	sort.SliceStable(results, func(i, j int) bool {
+		a := results[i].Locations[0].PhysicalLocation
+		b := results[j].Locations[0].PhysicalLocation
+		if a.ArtifactLocation.URI != b.ArtifactLocation.URI {
+			return a.ArtifactLocation.URI < b.ArtifactLocation.URI
+		}
+		return a.Region.before(b.Region)
+	})
+
+	type driver struct {
+		Name           string      `json:"name"`
+		Version        string      `json:"version,omitempty"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	}
+	type invocation struct {
+		ExecutionSuccessful bool `json:"executionSuccessful"`
+
+		Notifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+	}
+	type run struct {
+		Tool struct {
+			Driver driver `json:"driver"`
+		} `json:"tool"`
+		Invocations []invocation  `json:"invocations"`
+		Results     []sarifResult `json:"results"`
+		Properties  struct {
+			Summary runSummary `json:"summary"`
+		} `json:"properties"`
+	}
+
+	var sarifRun run
+	sarifRun.Tool.Driver = driver{
+		Name:           "link-patrol",
+		Version:        r.version,
+		InformationURI: "https://github.com/rednafi/link-patrol",
+		Rules:          sarifRules,
+	}
+	sarifRun.Invocations = []invocation{{
+		ExecutionSuccessful: len(r.notifications) == 0,
+		Notifications:       r.notifications,
+	}}
+	sarifRun.Results = results
+	sarifRun.Properties.Summary = summary
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Schema  string `json:"$schema"`
+		Version string `json:"version"`
+		Runs    []run  `json:"runs"`
+	}{sarifSchema, sarifVersion, []run{sarifRun}})
+}
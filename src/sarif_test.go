@@ -0,0 +1,210 @@
+package src
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// validateSARIF checks a document against the SARIF 2.1.0 schema and
+// returns it decoded.
+func validateSARIF(t *testing.T, doc []byte) map[string]any {
+	t.Helper()
+	schema, err := jsonschema.Compile(filepath.Join("testdata", "sarif-schema-2.1.0.json"))
+	require.NoError(t, err)
+
+	var v map[string]any
+	require.NoError(t, json.Unmarshal(doc, &v))
+	require.NoError(t, schema.Validate(v))
+	return v
+}
+
+// sarifResults returns the results of the only run of a SARIF document.
+func sarifResults(v map[string]any) []any {
+	return v["runs"].([]any)[0].(map[string]any)["results"].([]any)
+}
+
+// physicalLocation returns the physical location of a decoded SARIF result.
+func physicalLocation(result map[string]any) map[string]any {
+	loc := result["locations"].([]any)[0].(map[string]any)
+	return loc["physicalLocation"].(map[string]any)
+}
+
+func TestProblemRule(t *testing.T) {
+	t.Parallel()
+	permanent := []redirectHop{{"https://a.com", 301}, {"https://b.com", 200}}
+	temporary := []redirectHop{{"https://a.com", 302}, {"https://b.com", 200}}
+	tests := []struct {
+		record linkRecord
+		want   string
+	}{
+		{linkRecord{Severity: severityError, StatusCode: 404}, "error-status"},
+		{linkRecord{Severity: severityError, Message: "no such host"}, "unreachable"},
+		{linkRecord{Severity: severityError, Redirects: temporary}, "redirect-loop"},
+		{linkRecord{Severity: severityError, StatusCode: 200}, "missing-anchor"},
+		{linkRecord{Severity: severityError, Target: targetFile}, "broken-local-link"},
+		{linkRecord{Severity: severityWarning, Throttled: true}, "throttled"},
+		{linkRecord{Severity: severityWarning, Soft404: true}, "soft-404"},
+		{linkRecord{Severity: severityWarning, Redirects: permanent}, "permanent-redirect"},
+		{linkRecord{Severity: severityWarning, StatusCode: 403}, "accepted-status"},
+		{linkRecord{Severity: severityInfo, Redirects: temporary}, "temporary-redirect"},
+		{
+			linkRecord{Severity: severityInfo, Message: unverifiedAnchor + " #a: timeout"},
+			"unverified-anchor",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.want, problemRule(tt.record))
+		})
+	}
+}
+
+func TestSARIFReport(t *testing.T) {
+	t.Parallel()
+	r := newSARIFReport("0.1.0-test")
+	occurrences := []linkOccurrence{
+		{Filepath: "docs/b.md", Line: 7, Column: 3},
+		{Filepath: "a.md", Line: 2, Column: 1},
+	}
+	require.NoError(t, r.record(nil, linkRecord{
+		Location:    "https://example.com/gone",
+		StatusCode:  404,
+		Message:     "Not Found",
+		Severity:    severityError,
+		Occurrences: occurrences,
+	}))
+	require.NoError(t, r.record(nil, linkRecord{
+		Location:   "https://example.com/old",
+		StatusCode: 200,
+		OK:         true,
+		Message:    "OK",
+		Warning:    "Permanently redirected, update the link to https://example.com/new",
+		Redirects: []redirectHop{
+			{"https://example.com/old", 301}, {"https://example.com/new", 200},
+		},
+		Severity:    severityWarning,
+		Occurrences: occurrences[1:],
+	}))
+	require.NoError(t, r.record(
+		nil,
+		linkRecord{Location: "https://example.com", OK: true, Severity: severityOK},
+	))
+	require.NoError(t, r.directive(nil, directive{
+		Kind: disableNextLine, Filepath: "a.md", Line: 1, Column: 1,
+	}))
+
+	var buf bytes.Buffer
+	require.NoError(t, r.finish(&buf, runSummary{Files: 2, Links: 3, Error: 2}))
+	v := validateSARIF(t, buf.Bytes())
+
+	run := v["runs"].([]any)[0].(map[string]any)
+	summary := run["properties"].(map[string]any)["summary"].(map[string]any)
+	assert.Equal(t, 3.0, summary["links"])
+	assert.Equal(t, 2.0, summary["error"])
+	driver := run["tool"].(map[string]any)["driver"].(map[string]any)
+	assert.Equal(t, "link-patrol", driver["name"])
+	assert.Equal(t, "0.1.0-test", driver["version"])
+	assert.Len(t, driver["rules"], len(sarifRules))
+	assert.Equal(
+		t,
+		true,
+		run["invocations"].([]any)[0].(map[string]any)["executionSuccessful"],
+	)
+
+	// Results are sorted by location, one per occurrence
+	var got []string
+	for _, res := range sarifResults(v) {
+		res := res.(map[string]any)
+		loc := physicalLocation(res)
+		region := loc["region"].(map[string]any)
+		rule := sarifRules[int(res["ruleIndex"].(float64))]
+		assert.Equal(t, rule.ID, res["ruleId"])
+		got = append(got, fmt.Sprintf("%s:%v:%v %s %s %s",
+			loc["artifactLocation"].(map[string]any)["uri"],
+			region["startLine"], region["startColumn"],
+			res["ruleId"], res["level"], res["message"].(map[string]any)["text"],
+		))
+	}
+	assert.Equal(t, []string{
+		"a.md:1:1 unused-directive warning " +
+			"link-patrol-disable-next-line: Unused, no link is disabled by it",
+		"a.md:2:1 error-status error https://example.com/gone: Not Found (status 404)",
+		"a.md:2:1 permanent-redirect warning https://example.com/old: " +
+			"Permanently redirected, update the link to https://example.com/new " +
+			"(status 200)",
+		"docs/b.md:7:3 error-status error https://example.com/gone: Not Found (status 404)",
+	}, got)
+}
+
+func TestSARIFReport_FileError(t *testing.T) {
+	t.Parallel()
+	r := newSARIFReport("")
+	require.NoError(
+		t,
+		r.fileError(nil, "/docs/a.txt", errors.New("file is not a markdown file")),
+	)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.finish(&buf, runSummary{}))
+	v := validateSARIF(t, buf.Bytes())
+
+	run := v["runs"].([]any)[0].(map[string]any)
+	invocation := run["invocations"].([]any)[0].(map[string]any)
+	assert.Equal(t, false, invocation["executionSuccessful"])
+	notification := invocation["toolExecutionNotifications"].([]any)[0].(map[string]any)
+	assert.Equal(t, "error", notification["level"])
+	assert.Equal(
+		t,
+		"file is not a markdown file",
+		notification["message"].(map[string]any)["text"],
+	)
+
+	loc := notification["locations"].([]any)[0].(map[string]any)["physicalLocation"]
+	assert.Equal(t, map[string]any{"uri": "file:///docs/a.txt"},
+		loc.(map[string]any)["artifactLocation"])
+	assert.Empty(t, sarifResults(v))
+}
+
+func TestCLI_SARIF(t *testing.T) {
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/gone" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}),
+	)
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "links.md")
+	require.NoError(t, os.WriteFile(path, []byte(
+		"[ok]("+ts.URL+"/ok)\n\n[gone]("+ts.URL+"/gone)\n",
+	), 0o600))
+
+	code := 0
+	var out bytes.Buffer
+	os.Args = []string{os.Args[0], "-f", path, "--format", "sarif", "--show-skipped"}
+	CLI(&out, "0.1.0-test", func(c int) { code = c })
+
+	// The output is a single document and nothing else
+	assert.Equal(t, 1, code)
+	v := validateSARIF(t, out.Bytes())
+	results := sarifResults(v)
+	require.Len(t, results, 1)
+	result := results[0].(map[string]any)
+	assert.Equal(t, "error-status", result["ruleId"])
+	assert.Equal(t, "error", result["level"])
+	loc := physicalLocation(result)
+	assert.Equal(t, map[string]any{"startLine": 3.0, "startColumn": 1.0}, loc["region"])
+}
@@ -0,0 +1,82 @@
+package src
+
+import "errors"
+
+// severity tells how bad the result of a link is.
+type severity string
+
+const (
+	severitySkipped severity = "skipped"
+	severityOK      severity = "ok"
+	severityInfo    severity = "info"
+	severityWarning severity = "warning"
+	severityError   severity = "error"
+
+	// failNever is the --fail-on value that never fails a run. No record
+	// has it.
+	failNever severity = "never"
+)
+
+// severityRanks orders the severities. Skipped links rank like OK ones so
+// that they never fail a run.
+var severityRanks = map[severity]int{
+	severitySkipped: 0,
+	severityOK:      0,
+	severityInfo:    1,
+	severityWarning: 2,
+	severityError:   3,
+	failNever:       4,
+}
+
+// failOnLevels lists the values accepted by --fail-on.
+var failOnLevels = map[severity]bool{
+	severityInfo:    true,
+	severityWarning: true,
+	severityError:   true,
+	failNever:       true,
+}
+
+// atLeast reports whether s is as bad as t or worse.
+func (s severity) atLeast(t severity) bool {
+	return severityRanks[s] >= severityRanks[t]
+}
+
+// classify returns the severity of a record, unless it already has one.
+// Dead links are errors, throttled hosts, soft 404s and permanent redirects
+// are warnings, and other redirects are info.
+func classify(lr linkRecord) severity {
+	switch {
+	case lr.Severity != "":
+		return lr.Severity
+	case lr.Skipped:
+		return severitySkipped
+	case lr.Throttled:
+		return severityWarning
+	case !lr.OK:
+		return severityError
+	case lr.Warning != "":
+		return severityWarning
+	case len(lr.Redirects) > 0:
+		return severityInfo
+	}
+	return severityOK
+}
+
+// failure returns the error that a record fails the run with.
+func failure(lr linkRecord) error {
+	switch {
+	case lr.Severity == severityInfo:
+		return errors.New("one or more links have notices")
+	case lr.Severity == severityWarning:
+		return errors.New("one or more links have warnings")
+	case lr.StatusCode >= 400:
+		return errors.New("one or more URLs have error status codes")
+	case lr.Target == targetFile:
+		return errors.New("one or more local links are broken")
+	case lr.StatusCode > 0:
+		return errors.New("one or more anchors are missing")
+	case len(lr.Redirects) > 0:
+		return errors.New("one or more URLs redirect in a loop or too many times")
+	}
+	return errors.New("one or more URLs are unreachable")
+}
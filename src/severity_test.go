@@ -0,0 +1,126 @@
+package src
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		record linkRecord
+		want   severity
+	}{
+		{"ok", linkRecord{OK: true}, severityOK},
+		{"skipped", linkRecord{Skipped: true}, severitySkipped},
+		{"throttled", linkRecord{Throttled: true, StatusCode: 429}, severityWarning},
+		{"dead", linkRecord{StatusCode: 404}, severityError},
+		{"unreachable", linkRecord{Message: "no such host"}, severityError},
+		{
+			"soft 404",
+			linkRecord{OK: true, Soft404: true, Warning: "Suspected soft 404"},
+			severityWarning,
+		},
+		{
+			"temporary redirect",
+			linkRecord{OK: true, Redirects: []redirectHop{{}, {}}},
+			severityInfo,
+		},
+		{"preset", linkRecord{OK: true, Severity: severityInfo}, severityInfo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, classify(tt.record))
+		})
+	}
+}
+
+func TestSeverity_AtLeast(t *testing.T) {
+	t.Parallel()
+	assert.True(t, severityError.atLeast(severityWarning))
+	assert.True(t, severityWarning.atLeast(severityWarning))
+	assert.False(t, severityInfo.atLeast(severityWarning))
+	assert.False(t, severitySkipped.atLeast(severityInfo))
+	assert.False(t, severityError.atLeast(failNever))
+}
+
+func TestCheckLinks_FailOn(t *testing.T) {
+	t.Parallel()
+	ts := redirectServer()
+	defer ts.Close()
+
+	c := newChecker(options{Timeout: time.Second, MaxRetries: 1, MaxRedirects: 10})
+	defer c.close()
+
+	tests := []struct {
+		path   string
+		failOn severity
+		want   string
+	}{
+		{"/old", severityError, ""},
+		{"/old", severityWarning, "one or more links have warnings"},
+		{"/temp", severityWarning, ""},
+		{"/temp", severityInfo, "one or more links have notices"},
+		{"/ping", severityError, "one or more URLs redirect in a loop or too many times"},
+		{"/ping", failNever, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path+" "+string(tt.failOn), func(t *testing.T) {
+			var buf bytes.Buffer
+			err := checkLinks(&buf, linksOf(ts.URL+tt.path), c, tt.failOn)
+			if tt.want == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCLI_FailOn(t *testing.T) {
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		}),
+	)
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "links.md")
+	require.NoError(t, os.WriteFile(path, []byte("[old]("+ts.URL+"/old)"), 0o600))
+
+	run := func(args ...string) (string, int) {
+		code := 0
+		var out bytes.Buffer
+		os.Args = append([]string{os.Args[0], "-f", path}, args...)
+		CLI(&out, "0.1.0-test", func(c int) { code = c })
+		return out.String(), code
+	}
+
+	// Without following the redirect, the link is OK
+	out, code := run("--max-redirects", "0")
+	assert.Contains(t, out, "Severity   : ok")
+	assert.Equal(t, 0, code)
+
+	// Every path redirects to /new, so following them ends in a loop
+	out, code = run()
+	assert.Contains(t, out, "Severity   : error")
+	assert.Equal(t, 1, code)
+
+	out, code = run("--fail-on", "never")
+	assert.Contains(t, out, "Severity   : error")
+	assert.Equal(t, 0, code)
+
+	_, code = run("--fail-on", "sometimes")
+	assert.Equal(t, 2, code)
+}
@@ -0,0 +1,235 @@
+package src
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// defaultSoft404Patterns are the texts that mark a page as missing unless
+// --soft-404-pattern says otherwise.
+var defaultSoft404Patterns = []string{
+	"page not found",
+	"404 not found",
+	"page does not exist",
+	"page doesn't exist",
+}
+
+// minSoft404Similarity is how many of their words a page and the probe of a
+// nonexistent path on its host need to share to count as the same page.
+const minSoft404Similarity = 0.9
+
+// pageSummary is what soft 404 detection compares pages by. URL is where the
+// page ended up after redirects.
+type pageSummary struct {
+	URL        string
+	Redirected bool
+	Title      string
+	Words      map[string]bool
+}
+
+// soft404Detector flags pages that answer 200 but look like a missing page,
+// either because their title or text matches a pattern or because they look
+// just like the page a nonexistent path on the same host gets. Probes are
+// made once per host. It's safe for concurrent use.
+type soft404Detector struct {
+	client   *http.Client
+	patterns []soft404Pattern
+	mu       sync.Mutex
+	probes   map[string]*soft404Probe
+}
+
+// soft404Pattern is a --soft-404-pattern value and what it compiles to.
+type soft404Pattern struct {
+	raw string
+	re  *regexp.Regexp
+}
+
+// soft404Probe is the page returned for a nonexistent path on a host, nil
+// if the host answers such paths with an error like it should.
+type soft404Probe struct {
+	once sync.Once
+	page *pageSummary
+}
+
+// newSoft404Detector compiles the patterns. Plain patterns match the text
+// case insensitively and patterns prefixed with re: are regexes. Pages are
+// fetched through transport, the default one when nil.
+func newSoft404Detector(
+	timeout time.Duration,
+	transport http.RoundTripper,
+	patterns []string,
+) (*soft404Detector, error) {
+	d := &soft404Detector{
+		client: &http.Client{Timeout: timeout, Transport: transport},
+		probes: make(map[string]*soft404Probe),
+	}
+	for _, raw := range patterns {
+		expr, ok := strings.CutPrefix(raw, regexPrefix)
+		if !ok {
+			expr = "(?i)" + regexp.QuoteMeta(raw)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("soft-404-pattern: invalid pattern %q: %w", raw, err)
+		}
+		d.patterns = append(d.patterns, soft404Pattern{raw: raw, re: re})
+	}
+	return d, nil
+}
+
+// detect returns why the page at rawURL looks like a soft 404, or an empty
+// string if it doesn't or can't be fetched.
+func (d *soft404Detector) detect(rawURL string) string {
+	page, text, err := d.fetch(rawURL)
+	if err != nil || page == nil {
+		return ""
+	}
+
+	for _, p := range d.patterns {
+		if p.re.MatchString(page.Title) || p.re.MatchString(text) {
+			return fmt.Sprintf("Suspected soft 404, the page matches %q", p.raw)
+		}
+	}
+
+	// The home page of a host looks like the page its missing paths redirect
+	// to, so it's never compared.
+	u, err := url.Parse(rawURL)
+	if err != nil || strings.Trim(u.Path, "/") == "" {
+		return ""
+	}
+	probe := d.probe(u)
+	switch {
+	case probe == nil:
+		return ""
+	case page.Redirected && probe.Redirected && page.URL == probe.URL:
+		return "Suspected soft 404, redirects where a nonexistent path does"
+	case page.Title == probe.Title &&
+		similarity(page.Words, probe.Words) >= minSoft404Similarity:
+		return "Suspected soft 404, looks like the page of a nonexistent path"
+	}
+	return ""
+}
+
+// probe returns the page that a random path on the host of u gets, or nil
+// if the host answers it with an error.
+func (d *soft404Detector) probe(u *url.URL) *pageSummary {
+	key := strings.ToLower(u.Scheme + "://" + u.Host)
+
+	d.mu.Lock()
+	probe, ok := d.probes[key]
+	if !ok {
+		probe = &soft404Probe{}
+		d.probes[key] = probe
+	}
+	d.mu.Unlock()
+
+	probe.once.Do(func() {
+		b := make([]byte, 12)
+		_, _ = rand.Read(b)
+		probe.page, _, _ = d.fetch(key + "/" + hex.EncodeToString(b))
+	})
+	return probe.page
+}
+
+// fetch downloads the HTML page at rawURL and summarizes it along with its
+// text. Pages that don't answer with 200 or aren't HTML summarize to nil.
+func (d *soft404Detector) fetch(rawURL string) (*pageSummary, string, error) {
+	resp, err := d.client.Get(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	defer func() { _, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrain)) }()
+
+	if resp.StatusCode != http.StatusOK ||
+		!strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		return nil, "", nil
+	}
+
+	// Missing pages often repeat the path they were asked for, which would
+	// set them apart from the probe.
+	title, text := htmlText(io.LimitReader(resp.Body, maxPageSize))
+	words := wordSet(text)
+	if segment := path.Base(resp.Request.URL.Path); segment != "/" && segment != "." {
+		for w := range words {
+			if strings.Contains(w, strings.ToLower(segment)) {
+				delete(words, w)
+			}
+		}
+	}
+
+	return &pageSummary{
+		URL:        resp.Request.URL.String(),
+		Redirected: resp.Request.Response != nil,
+		Title:      title,
+		Words:      words,
+	}, text, nil
+}
+
+// htmlText returns the title and the visible text of an HTML page, with
+// runs of whitespace collapsed.
+func htmlText(r io.Reader) (string, string) {
+	var title, text strings.Builder
+	z := html.NewTokenizer(r)
+	skip := ""
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.Join(strings.Fields(title.String()), " "),
+				strings.Join(strings.Fields(text.String()), " ")
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "script", "style", "title":
+				skip = string(name)
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == skip {
+				skip = ""
+			}
+		case html.TextToken:
+			switch skip {
+			case "":
+				text.Write(z.Text())
+				text.WriteByte(' ')
+			case "title":
+				title.Write(z.Text())
+			}
+		}
+	}
+}
+
+// wordSet returns the lowercased words of the text.
+func wordSet(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, w := range strings.Fields(strings.ToLower(text)) {
+		words[w] = true
+	}
+	return words
+}
+
+// similarity returns the Jaccard index of two word sets, 1 when both are
+// empty.
+func similarity(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	shared := 0
+	for w := range a {
+		if b[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
@@ -0,0 +1,149 @@
+package src
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/tabwriter"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// soft404Server serves a few pages and answers every unknown path with a
+// 200 that says there's nothing there, or with a redirect to the home page,
+// like many sites do.
+func soft404Server(redirectMissing bool) *httptest.Server {
+	page := func(w http.ResponseWriter, title, body string) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<html><head><title>%s</title><style>p{}</style></head>"+
+			"<body><nav>Home Blog About</nav><p>%s</p></body></html>", title, body)
+	}
+	return httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/", "/home":
+				page(w, "Acme", "Welcome to Acme.")
+			case "/docs":
+				page(w, "Docs", "Everything about Acme and how to install it.")
+			case "/gone":
+				page(w, "Oops", "Page not found, try the search.")
+			case "/moved-away":
+				http.Redirect(w, r, "/home", http.StatusFound)
+			default:
+				if redirectMissing {
+					http.Redirect(w, r, "/home", http.StatusFound)
+					return
+				}
+				page(w, "Acme", "We looked everywhere but there's nothing at "+r.URL.Path)
+			}
+		}),
+	)
+}
+
+func TestSoft404Detector(t *testing.T) {
+	t.Parallel()
+	ts := soft404Server(false)
+	defer ts.Close()
+
+	d, err := newSoft404Detector(time.Second, nil, defaultSoft404Patterns)
+	require.NoError(t, err)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/docs", ""},
+		{"/", ""},
+		{"/gone", `Suspected soft 404, the page matches "page not found"`},
+		{"/missing", "Suspected soft 404, looks like the page of a nonexistent path"},
+		{"/moved-away", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, d.detect(ts.URL+tt.path))
+		})
+	}
+}
+
+func TestSoft404Detector_Redirect(t *testing.T) {
+	t.Parallel()
+	ts := soft404Server(true)
+	defer ts.Close()
+
+	d, err := newSoft404Detector(time.Second, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Suspected soft 404, redirects where a nonexistent path does",
+		d.detect(ts.URL+"/moved-away"))
+	assert.Empty(t, d.detect(ts.URL+"/docs"))
+}
+
+func TestNewSoft404Detector_Patterns(t *testing.T) {
+	t.Parallel()
+	d, err := newSoft404Detector(
+		time.Second,
+		nil,
+		[]string{"Gone (for good)", `re:^Error \d+`},
+	)
+	require.NoError(t, err)
+	assert.True(t, d.patterns[0].re.MatchString("it's gone (FOR GOOD)"))
+	assert.True(t, d.patterns[1].re.MatchString("Error 404"))
+	assert.False(t, d.patterns[1].re.MatchString("error 404"))
+
+	_, err = newSoft404Detector(time.Second, nil, []string{"re:("})
+	assert.ErrorContains(t, err, `soft-404-pattern: invalid pattern "re:("`)
+}
+
+func TestHTMLText(t *testing.T) {
+	t.Parallel()
+	title, text := htmlText(strings.NewReader(
+		"<title> Not\n Found </title><script>var a = 1</script>" +
+			"<h1>Sorry,</h1>\n<p>it's  gone</p>",
+	))
+	assert.Equal(t, "Not Found", title)
+	assert.Equal(t, "Sorry, it's gone", text)
+}
+
+func TestSimilarity(t *testing.T) {
+	t.Parallel()
+	assert.InDelta(t, 0.5, similarity(wordSet("a b c"), wordSet("b c d")), 1e-9)
+	assert.InDelta(t, 1.0, similarity(wordSet(""), wordSet("")), 1e-9)
+	assert.InDelta(t, 0.0, similarity(wordSet("a"), wordSet("b")), 1e-9)
+}
+
+func TestCheckLinks_Soft404(t *testing.T) {
+	t.Parallel()
+	ts := soft404Server(false)
+	defer ts.Close()
+
+	d, err := newSoft404Detector(time.Second, nil, defaultSoft404Patterns)
+	require.NoError(t, err)
+	c := newChecker(options{
+		Timeout:      time.Second,
+		MaxRetries:   1,
+		MaxRedirects: defaultMaxRedirects,
+		Soft404:      d,
+	})
+	defer c.close()
+
+	buf := new(bytes.Buffer)
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	err = checkLinks(w, linksOf(ts.URL+"/gone"), c, severityError)
+	w.Flush()
+
+	// A soft 404 is a warning, it doesn't fail the run
+	assert.NoError(t, err)
+	assert.Equal(t, "- Location   : "+ts.URL+"/gone\n"+
+		"  Status Code: 200\n"+
+		"  OK         : true\n"+
+		"  Severity   : warning\n"+
+		"  Soft 404   : true\n"+
+		"  Message    : OK\n"+
+		"  Warning    : Suspected soft 404, the page matches \"page not found\"\n"+
+		"  Attempt    : 1\n"+
+		"  Position   : test.md:1:1\n\n", buf.String())
+}
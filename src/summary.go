@@ -0,0 +1,194 @@
+package src
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// slowestHosts is the number of hosts listed in the summary.
+const slowestHosts = 3
+
+// runSummary gives an overview of a run. Links counts every occurrence, by
+// severity, while URLs counts the unique ones that were checked. Retries and
+// host timings only cover the requests of those unique URLs.
+type runSummary struct {
+	Files        int          `json:"files"`
+	Links        int          `json:"links"`
+	URLs         int          `json:"urls"`
+	OK           int          `json:"ok"`
+	Info         int          `json:"info"`
+	Warning      int          `json:"warning"`
+	Error        int          `json:"error"`
+	Skipped      int          `json:"skipped"`
+	Directives   int          `json:"directives"`
+	FileErrors   int          `json:"fileErrors"`
+	Retries      int          `json:"retries"`
+	SlowestHosts []hostTiming `json:"slowestHosts"`
+	Failed       bool         `json:"failed"`
+	DurationMs   float64      `json:"durationMs"`
+}
+
+// hostTiming is how long the requests to a host took.
+type hostTiming struct {
+	Host      string  `json:"host"`
+	Requests  int     `json:"requests"`
+	AverageMs float64 `json:"averageMs"`
+	TotalMs   float64 `json:"totalMs"`
+}
+
+// runStats counts the results of a run as they come in. It's safe for
+// concurrent use.
+type runStats struct {
+	started time.Time
+	mu      sync.Mutex
+	summary runSummary
+}
+
+func newRunStats() *runStats {
+	return &runStats{started: time.Now()}
+}
+
+// addFile counts a file.
+func (s *runStats) addFile() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.summary.Files++
+}
+
+// add counts the occurrences of records by severity.
+func (s *runStats) add(records ...linkRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, lr := range records {
+		n := len(lr.Occurrences)
+		s.summary.Links += n
+		switch lr.Severity {
+		case severitySkipped:
+			s.summary.Skipped += n
+		case severityOK:
+			s.summary.OK += n
+		case severityInfo:
+			s.summary.Info += n
+		case severityWarning:
+			s.summary.Warning += n
+		case severityError:
+			s.summary.Error += n
+		}
+	}
+}
+
+// addDirective counts an unused directive.
+func (s *runStats) addDirective() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.summary.Directives++
+}
+
+// addFileError counts a file that couldn't be checked.
+func (s *runStats) addFileError() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.summary.FileErrors++
+}
+
+// finish returns the summary of the run. The unique URLs, retries and host
+// timings come from the records of the cache.
+func (s *runStats) finish(cache *linkCache, failed bool) runSummary {
+	s.mu.Lock()
+	summary := s.summary
+	s.mu.Unlock()
+
+	timings := make(map[string]*hostTiming)
+	records := cache.records()
+	summary.URLs = len(records)
+	for _, lr := range records {
+		summary.Retries += max(lr.Attempt-1, 0)
+		if lr.Target != targetHTTP || len(lr.Attempts) == 0 {
+			continue
+		}
+		host := hostOf(lr.Location)
+		t, ok := timings[host]
+		if !ok {
+			t = &hostTiming{Host: host}
+			timings[host] = t
+		}
+		for _, a := range lr.Attempts {
+			t.Requests++
+			t.TotalMs += a.DurationMs
+		}
+	}
+
+	summary.SlowestHosts = []hostTiming{}
+	for _, t := range timings {
+		t.AverageMs = t.TotalMs / float64(t.Requests)
+		summary.SlowestHosts = append(summary.SlowestHosts, *t)
+	}
+	sort.Slice(summary.SlowestHosts, func(i, j int) bool {
+		a, b := summary.SlowestHosts[i], summary.SlowestHosts[j]
+		if a.AverageMs != b.AverageMs {
+			return a.AverageMs > b.AverageMs
+		}
+		return a.Host < b.Host
+	})
+	if len(summary.SlowestHosts) > slowestHosts {
+		summary.SlowestHosts = summary.SlowestHosts[:slowestHosts]
+	}
+
+	summary.Failed = failed
+	summary.DurationMs = milliseconds(time.Since(s.started))
+	return summary
+}
+
+// printSummary prints the summary of a run in tabular format.
+func printSummary(w io.Writer, s runSummary) error {
+	var b strings.Builder
+	b.WriteString("Summary:\n\n")
+	fmt.Fprintf(&b, "- Files      : %d\n", s.Files)
+	fmt.Fprintf(&b, "  Links      : %d, %d unique URLs checked\n", s.Links, s.URLs)
+	fmt.Fprintf(&b, "  OK         : %d\n", s.OK)
+	fmt.Fprintf(&b, "  Notices    : %d\n", s.Info)
+	fmt.Fprintf(&b, "  Warnings   : %d\n", s.Warning)
+	fmt.Fprintf(&b, "  Errors     : %d\n", s.Error)
+	fmt.Fprintf(&b, "  Skipped    : %d\n", s.Skipped)
+	if s.Directives > 0 {
+		fmt.Fprintf(&b, "  Directives : %d unused\n", s.Directives)
+	}
+	if s.FileErrors > 0 {
+		fmt.Fprintf(&b, "  File Errors: %d\n", s.FileErrors)
+	}
+	fmt.Fprintf(&b, "  Retries    : %d\n", s.Retries)
+	for i, t := range s.SlowestHosts {
+		label := "              "
+		if i == 0 {
+			label = "  Slowest    :"
+		}
+		fmt.Fprintf(&b, "%s %s, %s on average over %s\n",
+			label, t.Host, formatMs(t.AverageMs), plural(t.Requests, "request"))
+	}
+	fmt.Fprintf(&b, "  Wall Time  : %s\n\n", formatMs(s.DurationMs))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// formatMs formats milliseconds as a duration rounded to the millisecond,
+// or to the microsecond below that.
+func formatMs(ms float64) string {
+	d := time.Duration(ms * float64(time.Millisecond))
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// plural formats a count of things, like 1 request or 2 requests.
+func plural(n int, thing string) string {
+	if n == 1 {
+		return "1 " + thing
+	}
+	return fmt.Sprintf("%d %ss", n, thing)
+}
@@ -0,0 +1,184 @@
+package src
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunStats(t *testing.T) {
+	t.Parallel()
+	s := newRunStats()
+	s.addFile()
+	s.addFile()
+	s.add(
+		linkRecord{Severity: severityOK, Occurrences: linksOf("a", "a")},
+		linkRecord{Severity: severityError, Occurrences: linksOf("b")},
+		linkRecord{Severity: severitySkipped, Occurrences: linksOf("c")},
+	)
+	s.add(linkRecord{Severity: severityWarning, Occurrences: linksOf("d")})
+	s.addDirective()
+	s.addFileError()
+
+	cache := newLinkCache()
+	for _, lr := range []linkRecord{
+		{
+			Location: "https://slow.example.com/a",
+			Target:   targetHTTP,
+			Attempt:  3,
+			Attempts: []attemptRecord{
+				{DurationMs: 300}, {DurationMs: 100}, {DurationMs: 200},
+			},
+		},
+		{
+			Location: "https://fast.example.com/b",
+			Target:   targetHTTP,
+			Attempt:  1,
+			Attempts: []attemptRecord{{DurationMs: 10}},
+		},
+		{
+			Location: "https://slow.example.com/c",
+			Target:   targetHTTP,
+			Attempt:  1,
+			Attempts: []attemptRecord{{DurationMs: 200}},
+		},
+		{Location: "a.md", Target: targetFile},
+	} {
+		cache.check(lr.Location, func() linkRecord { return lr })
+	}
+
+	summary := s.finish(cache, true)
+	assert.Greater(t, summary.DurationMs, 0.0)
+	summary.DurationMs = 0
+	assert.Equal(t, runSummary{
+		Files:      2,
+		Links:      5,
+		URLs:       4,
+		OK:         2,
+		Warning:    1,
+		Error:      1,
+		Skipped:    1,
+		Directives: 1,
+		FileErrors: 1,
+		Retries:    2,
+		SlowestHosts: []hostTiming{
+			{Host: "slow.example.com", Requests: 4, AverageMs: 200, TotalMs: 800},
+			{Host: "fast.example.com", Requests: 1, AverageMs: 10, TotalMs: 10},
+		},
+		Failed: true,
+	}, summary)
+}
+
+func TestRunStats_SlowestHostsAreCapped(t *testing.T) {
+	t.Parallel()
+	cache := newLinkCache()
+	for _, host := range []string{"a.com", "b.com", "c.com", "d.com"} {
+		cache.check(host, func() linkRecord {
+			return linkRecord{
+				Location: "https://" + host,
+				Target:   targetHTTP,
+				Attempt:  1,
+				Attempts: []attemptRecord{{DurationMs: float64(len(host))}},
+			}
+		})
+	}
+
+	// Hosts that are as slow are sorted by name
+	var hosts []string
+	for _, h := range newRunStats().finish(cache, false).SlowestHosts {
+		hosts = append(hosts, h.Host)
+	}
+	assert.Equal(t, []string{"a.com", "b.com", "c.com"}, hosts)
+}
+
+func TestPrintSummary(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	require.NoError(t, printSummary(&buf, runSummary{
+		Files:   2,
+		Links:   12,
+		URLs:    9,
+		OK:      8,
+		Info:    1,
+		Warning: 1,
+		Error:   1,
+		Skipped: 1,
+		Retries: 3,
+		SlowestHosts: []hostTiming{
+			{Host: "example.com", Requests: 3, AverageMs: 812.4},
+			{Host: "example.org", Requests: 1, AverageMs: 0.25},
+		},
+		DurationMs: 1520.6,
+	}))
+	assert.Equal(t, "Summary:\n\n"+
+		"- Files      : 2\n"+
+		"  Links      : 12, 9 unique URLs checked\n"+
+		"  OK         : 8\n"+
+		"  Notices    : 1\n"+
+		"  Warnings   : 1\n"+
+		"  Errors     : 1\n"+
+		"  Skipped    : 1\n"+
+		"  Retries    : 3\n"+
+		"  Slowest    : example.com, 812ms on average over 3 requests\n"+
+		"               example.org, 250µs on average over 1 request\n"+
+		"  Wall Time  : 1.521s\n\n",
+		buf.String(),
+	)
+
+	// Unused directives and file errors only show up when there are some
+	buf.Reset()
+	require.NoError(t, printSummary(&buf, runSummary{Directives: 2, FileErrors: 1}))
+	assert.Contains(t, buf.String(), "  Directives : 2 unused\n  File Errors: 1\n")
+	assert.NotContains(t, buf.String(), "Slowest")
+}
+
+func TestCLI_Summary(t *testing.T) {
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/gone" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}),
+	)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.md")
+	b := filepath.Join(dir, "b.md")
+	require.NoError(t, os.WriteFile(a, []byte(
+		"[ok]("+ts.URL+"/ok)\n\n[gone]("+ts.URL+"/gone)\n",
+	), 0o600))
+	require.NoError(t, os.WriteFile(b, []byte(
+		"[ok]("+ts.URL+"/ok)\n\n[dev](http://localhost:1/)\n",
+	), 0o600))
+
+	code := 0
+	var out bytes.Buffer
+	os.Args = []string{os.Args[0], "-f", dir, "--exclude", "http://localhost*"}
+	CLI(&out, "0.1.0-test", func(c int) { code = c })
+
+	// The summary comes last and counts the URL shared by both files once
+	assert.Equal(t, 1, code)
+	output := out.String()
+	summary := output[strings.Index(output, "Summary:"):]
+	assert.Contains(t, summary, ""+
+		"- Files      : 2\n"+
+		"  Links      : 4, 2 unique URLs checked\n"+
+		"  OK         : 2\n"+
+		"  Notices    : 0\n"+
+		"  Warnings   : 0\n"+
+		"  Errors     : 1\n"+
+		"  Skipped    : 1\n"+
+		"  Retries    : 0\n"+
+		"  Slowest    : 127.0.0.1, ",
+	)
+	assert.Contains(t, summary, " on average over 3 requests\n  Wall Time  : ")
+	assert.True(t, strings.HasSuffix(output, "\n\n"))
+}
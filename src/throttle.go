@@ -0,0 +1,75 @@
+package src
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostThrottle pauses every request to a host after one of them got
+// throttled, so that the host sees a single back-off instead of one per
+// in-flight request. A nil hostThrottle never pauses.
+type hostThrottle struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func newHostThrottle() *hostThrottle {
+	return &hostThrottle{until: make(map[string]time.Time)}
+}
+
+// pause holds back requests to the host for d. Pauses don't shorten a
+// longer one that's already in place.
+func (t *hostThrottle) pause(host string, d time.Duration) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until := time.Now().Add(d); until.After(t.until[host]) {
+		t.until[host] = until
+	}
+}
+
+// wait blocks until requests to the host aren't paused anymore.
+func (t *hostThrottle) wait(host string) {
+	if t == nil {
+		return
+	}
+
+	for {
+		t.mu.Lock()
+		d := time.Until(t.until[host])
+		t.mu.Unlock()
+
+		if d <= 0 {
+			return
+		}
+		time.Sleep(d)
+	}
+}
+
+// parseRetryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date. Dates in the past mean no wait.
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(header)
+	if err != nil {
+		return 0, false
+	}
+	return max(date.Sub(now), 0), true
+}
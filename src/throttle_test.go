@@ -0,0 +1,230 @@
+package src
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{" 0 ", 0, true},
+		{"Mon, 01 Jan 2024 12:00:30 GMT", 30 * time.Second, true},
+		{"Mon, 01 Jan 2024 11:00:00 GMT", 0, true},
+		{"", 0, false},
+		{"-1", 0, false},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.header, now)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHostThrottle(t *testing.T) {
+	t.Parallel()
+	throttle := newHostThrottle()
+	throttle.pause("example.com", 50*time.Millisecond)
+
+	// A shorter pause doesn't cut the longer one short
+	throttle.pause("example.com", time.Millisecond)
+
+	// Every request to the host waits, other hosts don't
+	start := time.Now()
+	throttle.wait("other.com")
+	assert.Less(t, time.Since(start), 25*time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			throttle.wait("example.com")
+			assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+		}()
+	}
+	wg.Wait()
+
+	// A nil throttle never waits
+	var none *hostThrottle
+	none.pause("example.com", time.Hour)
+	none.wait("example.com")
+}
+
+func TestCheckLinkWith_RetryAfter(t *testing.T) {
+	t.Parallel()
+	var hits atomic.Int32
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if hits.Add(1) == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	defer ts.Close()
+
+	lr := checkLinkWith(ts.URL, requestConfig{
+		Timeout:      time.Second,
+		MaxRetries:   2,
+		StartBackoff: time.Hour,
+		MaxBackoff:   time.Hour,
+		Throttle:     newHostThrottle(),
+	})
+
+	// The Retry-After of 0 replaces the hour long backoff
+	assert.True(t, lr.OK)
+	assert.Equal(t, 2, lr.Attempt)
+	assert.False(t, lr.Throttled)
+}
+
+func TestCheckLinkWith_Throttled(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/long" {
+				w.Header().Set("Retry-After", "120")
+			} else {
+				w.Header().Set("Retry-After", "0")
+			}
+			w.WriteHeader(http.StatusTooManyRequests)
+		}),
+	)
+	defer ts.Close()
+
+	cfg := requestConfig{
+		Timeout:       time.Second,
+		MaxRetries:    2,
+		StartBackoff:  time.Millisecond,
+		MaxBackoff:    time.Millisecond,
+		MaxRetryAfter: time.Minute,
+	}
+
+	lr := checkLinkWith(ts.URL+"/short", cfg)
+	assert.False(t, lr.OK)
+	assert.True(t, lr.Throttled)
+	assert.Equal(t, http.StatusTooManyRequests, lr.StatusCode)
+	assert.Equal(t, 2, lr.Attempt)
+	assert.Equal(t, "Throttled, gave up after 2 attempts", lr.Message)
+
+	// Retry-After beyond the maximum gives up right away
+	lr = checkLinkWith(ts.URL+"/long", cfg)
+	assert.True(t, lr.Throttled)
+	assert.Equal(t, 1, lr.Attempt)
+	assert.Equal(t,
+		"Throttled, Retry-After of 2m0s exceeds the maximum of 1m0s", lr.Message,
+	)
+}
+
+func TestCheckLinkWith_ServiceUnavailable(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/retry" {
+				w.Header().Set("Retry-After", "0")
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}),
+	)
+	defer ts.Close()
+
+	cfg := requestConfig{
+		Timeout:      time.Second,
+		MaxRetries:   1,
+		StartBackoff: time.Millisecond,
+		MaxBackoff:   time.Millisecond,
+	}
+
+	// A 503 without Retry-After is a plain server error
+	lr := checkLinkWith(ts.URL, cfg)
+	assert.False(t, lr.Throttled)
+	assert.Equal(t, "Service Unavailable", lr.Message)
+
+	lr = checkLinkWith(ts.URL+"/retry", cfg)
+	assert.True(t, lr.Throttled)
+}
+
+func TestCheckLinks_ThrottledIsNotAnError(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}),
+	)
+	defer ts.Close()
+
+	c := newChecker(options{
+		Timeout:      time.Second,
+		MaxRetries:   1,
+		StartBackoff: time.Millisecond,
+		MaxBackoff:   time.Millisecond,
+	})
+	defer c.close()
+
+	var buf bytes.Buffer
+	err := checkLinks(&buf, linksOf(ts.URL), c, severityError)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "  Throttled  : true\n")
+}
+
+func TestCheckLinks_ThrottledPausesTheHost(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var hits []time.Time
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hits = append(hits, time.Now())
+			mu.Unlock()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}),
+	)
+	defer ts.Close()
+
+	c := newChecker(options{
+		Timeout:         time.Second,
+		MaxRetries:      1,
+		Method:          methodGet,
+		HostConcurrency: 1,
+		StartBackoff:    time.Millisecond,
+		MaxBackoff:      time.Millisecond,
+	})
+	defer c.close()
+
+	// Every link gives up on its only attempt, but the next one still waits
+	// for the Retry-After of the host
+	var buf bytes.Buffer
+	urls := []string{ts.URL + "/a", ts.URL + "/b", ts.URL + "/c"}
+	assert.NoError(t, checkLinks(&buf, linksOf(urls...), c, severityError))
+	assert.Equal(t, 3, strings.Count(buf.String(), "gave up after 1 attempt\n"))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, hits, 3)
+	for i := 1; i < len(hits); i++ {
+		assert.GreaterOrEqual(t, hits[i].Sub(hits[i-1]), 900*time.Millisecond)
+	}
+}